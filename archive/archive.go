@@ -0,0 +1,170 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Supported archive formats.
+const (
+	Zip   = "zip"
+	TarGz = "tar.gz"
+)
+
+// Formats lists all supported archive formats.
+var Formats = []string{Zip, TarGz}
+
+// Archive streams a directory as a zip or tar.gz archive without writing a
+// temporary file.
+type Archive struct {
+	Dir    string
+	Format string
+}
+
+// New creates an Archive for dir. An empty format defaults to zip.
+func New(dir, format string) (*Archive, error) {
+	if format == "" {
+		format = Zip
+	}
+	if format != Zip && format != TarGz {
+		return nil, fmt.Errorf("unsupported archive format: %s", format)
+	}
+	return &Archive{Dir: dir, Format: format}, nil
+}
+
+// Name returns the upload filename, e.g. "dist.zip" for the directory ./dist.
+func (a *Archive) Name() string {
+	return filepath.Base(filepath.Clean(a.Dir)) + "." + a.Format
+}
+
+// ContentSize returns the total size of the regular files in the archive,
+// taken from the directory listing without reading them. The archive is
+// about this large for content that does not compress, so it serves as an
+// estimate while the archive is streamed.
+func (a *Archive) ContentSize() (int64, error) {
+	var size int64
+	err := a.walk(func(name string, info fs.FileInfo, p string) error {
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// Open starts streaming the archive in the background and returns a reader
+// for it. Closing the reader stops the stream.
+func (a *Archive) Open() io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(a.Build(pw))
+	}()
+	return pr
+}
+
+// Build writes the complete archive to w.
+func (a *Archive) Build(w io.Writer) error {
+	switch a.Format {
+	case TarGz:
+		return a.writeTarGz(w)
+	default:
+		return a.writeZip(w)
+	}
+}
+
+func (a *Archive) writeZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	err := a.walk(func(name string, info fs.FileInfo, p string) error {
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+			_, err = zw.CreateHeader(hdr)
+			return err
+		}
+		hdr.Method = zip.Deflate
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		return copyFile(fw, p)
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func (a *Archive) writeTarGz(w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	err := a.walk(func(name string, info fs.FileInfo, p string) error {
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		return copyFile(tw, p)
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// walk calls fn for every directory and regular file below Dir. Names are
+// slash-separated and prefixed with the directory's base name. Symlinks and
+// other special files are skipped.
+func (a *Archive) walk(fn func(name string, info fs.FileInfo, p string) error) error {
+	root := filepath.Clean(a.Dir)
+	base := filepath.Base(root)
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		return fn(path.Join(base, filepath.ToSlash(rel)), info, p)
+	})
+}
+
+func copyFile(w io.Writer, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,110 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func testDir(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "dist")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0o600)
+	os.WriteFile(filepath.Join(dir, "sub", "b.txt"), bytes.Repeat([]byte("x"), 4096), 0o600)
+	return dir
+}
+
+func TestName(t *testing.T) {
+	a, err := New("./dist/", TarGz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name() != "dist.tar.gz" {
+		t.Errorf("expected dist.tar.gz, got %q", a.Name())
+	}
+	if _, err := New("dist", "rar"); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestContentSize(t *testing.T) {
+	a, _ := New(testDir(t), Zip)
+	size, err := a.ContentSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 5+4096 {
+		t.Errorf("ContentSize = %d, want %d", size, 5+4096)
+	}
+}
+
+func TestZipStream(t *testing.T) {
+	a, _ := New(testDir(t), Zip)
+
+	rc := a.Open()
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	want := []string{"dist/", "dist/a.txt", "dist/sub/", "dist/sub/b.txt"}
+	if len(names) != len(want) {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, names)
+		}
+	}
+}
+
+func TestTarGzStream(t *testing.T) {
+	a, _ := New(testDir(t), TarGz)
+
+	data, err := io.ReadAll(a.Open())
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	tr := tar.NewReader(gr)
+	found := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		if hdr.Name == "dist/a.txt" {
+			b, _ := io.ReadAll(tr)
+			found = string(b) == "hello"
+		}
+	}
+	if !found {
+		t.Error("dist/a.txt missing or wrong content")
+	}
+}
@@ -2,10 +2,15 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"runtime"
+	"strings"
+
+	"schneider.vip/share/lockfile"
+	"schneider.vip/share/secret"
 )
 
 // ProviderEntry holds a single provider configuration.
@@ -13,6 +18,19 @@ type ProviderEntry struct {
 	Label    string            `json:"label"`
 	Type     string            `json:"type"`
 	Settings map[string]string `json:"settings"`
+
+	// secrets holds the settings resolved by Config.ResolveSecrets.
+	secrets map[string]storedSecret
+}
+
+// ShortenerEntry holds the URL shortener configuration.
+type ShortenerEntry struct {
+	Type     string            `json:"type"`
+	Settings map[string]string `json:"settings,omitempty"`
+
+	// secrets holds the settings resolved by
+	// Config.ResolveShortenerSecrets.
+	secrets map[string]storedSecret
 }
 
 // Config is the v2 configuration format supporting multiple providers.
@@ -23,7 +41,18 @@ type Config struct {
 	CopyToClipboard *bool           `json:"copy_to_clipboard,omitempty"`
 	ShowQRCode      *bool           `json:"show_qr_code,omitempty"`
 	SixelEnabled    *bool           `json:"sixel_enabled,omitempty"`
-	Path            string          `json:"-"`
+	ArchiveFormat   string          `json:"archive_format,omitempty"`
+	History         *bool           `json:"history,omitempty"`
+	Shortener       *ShortenerEntry `json:"shortener,omitempty"`
+	ShortenLinks    *bool           `json:"shorten_links,omitempty"`
+	// SecretStore keeps the SecretKeys settings out of the config file; see
+	// secret.Open for the values. Empty keeps them in the file.
+	SecretStore string `json:"secret_store,omitempty"`
+	Path        string `json:"-"`
+
+	store secret.Store
+	// removed are secrets of removed providers, deleted on Write.
+	removed []string
 }
 
 // CopyToClipboardEnabled returns whether clipboard copy is enabled (default: true).
@@ -50,6 +79,32 @@ func (c *Config) IsSixelEnabled() bool {
 	return *c.SixelEnabled
 }
 
+// HistoryEnabled returns whether uploads are recorded in the history (default: true).
+func (c *Config) HistoryEnabled() bool {
+	if c.History == nil {
+		return true
+	}
+	return *c.History
+}
+
+// ShortenLinksEnabled returns whether links are shortened without --short
+// (default: false).
+func (c *Config) ShortenLinksEnabled() bool {
+	if c.ShortenLinks == nil {
+		return false
+	}
+	return *c.ShortenLinks
+}
+
+// ArchiveFormatOrDefault returns the format used when uploading a directory
+// (default: zip).
+func (c *Config) ArchiveFormatOrDefault() string {
+	if c.ArchiveFormat == "" {
+		return "zip"
+	}
+	return c.ArchiveFormat
+}
+
 // configV1 is the legacy single-provider format (version 1 / no version field).
 type configV1 struct {
 	Provider             string            `json:"provider"`
@@ -93,16 +148,54 @@ func (c *Config) FindByLabel(label string) *ProviderEntry {
 	return nil
 }
 
-// AddProvider appends a new provider entry.
-func (c *Config) AddProvider(entry ProviderEntry) {
+// AddProvider appends a new provider entry. Returns error if the label is
+// empty or already taken.
+func (c *Config) AddProvider(entry ProviderEntry) error {
+	if err := c.checkLabel(entry.Label); err != nil {
+		return err
+	}
 	c.Providers = append(c.Providers, entry)
+	return nil
+}
+
+// RenameProvider changes the label of a provider, and Active if it was the
+// active one. Returns error if it is not found or the new label is taken.
+func (c *Config) RenameProvider(label, newLabel string) error {
+	entry := c.FindByLabel(label)
+	if entry == nil {
+		return fmt.Errorf("provider %q not found", label)
+	}
+	if newLabel == label {
+		return nil
+	}
+	if err := c.checkLabel(newLabel); err != nil {
+		return err
+	}
+	entry.Label = newLabel
+	if c.Active == label {
+		c.Active = newLabel
+	}
+	return nil
+}
+
+// checkLabel returns an error if label can't be used for a new provider.
+func (c *Config) checkLabel(label string) error {
+	if strings.TrimSpace(label) == "" {
+		return errors.New("provider label must not be empty")
+	}
+	if c.FindByLabel(label) != nil {
+		return fmt.Errorf("provider %q already exists", label)
+	}
+	return nil
 }
 
 // RemoveProvider removes the provider with the given label.
-// If the removed provider was active, Active is cleared.
+// If the removed provider was active, Active is cleared. Its secrets are
+// deleted on the next Write.
 func (c *Config) RemoveProvider(label string) {
 	for i := range c.Providers {
 		if c.Providers[i].Label == label {
+			c.removed = append(c.removed, secretIDs(c.Providers[i].Settings, c.Providers[i].secrets)...)
 			c.Providers = append(c.Providers[:i], c.Providers[i+1:]...)
 			if c.Active == label {
 				c.Active = ""
@@ -130,21 +223,113 @@ func (c *Config) ProviderLabels() []string {
 	return labels
 }
 
-// Write saves the config to disk at its Path.
+// Write saves the config to disk at its Path, replacing what other
+// processes wrote meanwhile; use Update to change only parts of it. With a
+// SecretStore, secrets are saved in the store and the file only holds
+// references.
 func (c *Config) Write() error {
-	err := os.MkdirAll(path.Dir(c.Path), 0o700)
+	if err := os.MkdirAll(path.Dir(c.Path), 0o700); err != nil {
+		return err
+	}
+	unlock, err := lockfile.Lock(c.lockPath())
 	if err != nil {
 		return err
 	}
-	output, err := os.Create(c.Path)
+	defer unlock()
+	return c.save()
+}
+
+// Update changes the config on disk without losing changes of other
+// processes: it locks the config, loads it again, applies fn to the fresh
+// copy and saves it. c itself is not changed; callers that keep using it
+// apply the change to it as well.
+func (c *Config) Update(fn func(*Config) error) error {
+	if err := os.MkdirAll(path.Dir(c.Path), 0o700); err != nil {
+		return err
+	}
+	unlock, err := lockfile.Lock(c.lockPath())
 	if err != nil {
 		return err
 	}
-	defer output.Close()
+	defer unlock()
 
-	enc := json.NewEncoder(output)
-	enc.SetIndent("", "  ")
-	return enc.Encode(c)
+	fresh, err := LoadConfig(c.Path)
+	if err != nil {
+		return err
+	}
+	if fresh.SecretStore == c.SecretStore {
+		// Don't open the store, or ask for its passphrase, twice.
+		fresh.store = c.store
+	}
+	if err := fn(fresh); err != nil {
+		return err
+	}
+	if err := fresh.save(); err != nil {
+		return err
+	}
+	if fresh.SecretStore == c.SecretStore {
+		c.store = fresh.store
+	}
+	return nil
+}
+
+// lockPath is the file locked while the config is written. It is not the
+// config itself, which is replaced on every save.
+func (c *Config) lockPath() string {
+	return c.Path + ".lock"
+}
+
+// save writes the config atomically; the caller holds the lock.
+func (c *Config) save() error {
+	disk, err := c.externalize()
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(disk, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.Path, append(content, '\n'), 0o600); err != nil {
+		return err
+	}
+	c.deleteRemoved()
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to name, syncs it and
+// renames it over name, so that name holds either the old or the new content
+// even if the process dies.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	dir := path.Dir(name)
+	tmp, err := os.CreateTemp(dir, path.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil && runtime.GOOS != "windows" {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return err
+	}
+	// Persist the rename; not possible on all systems.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
 }
 
 // LoadConfig loads the config from disk, auto-migrating v1 format.
@@ -175,6 +360,12 @@ func LoadConfig(filepath string) (*Config, error) {
 			return nil, err
 		}
 		cfg.Path = filepath
+		for i := range cfg.Providers {
+			trackSecrets(cfg.Providers[i].Settings, &cfg.Providers[i].secrets)
+		}
+		if cfg.Shortener != nil {
+			trackSecrets(cfg.Shortener.Settings, &cfg.Shortener.secrets)
+		}
 		return cfg, nil
 	}
 
@@ -208,6 +399,16 @@ func migrateV1(data []byte, filepath string) (*Config, error) {
 		cfg.Active = v1.Provider
 	}
 
+	// v1 shortened every link once a shortener was configured.
+	if v1.URLShortenerProvider != "" {
+		cfg.Shortener = &ShortenerEntry{
+			Type:     v1.URLShortenerProvider,
+			Settings: v1.URLShortenerSettings,
+		}
+		shorten := true
+		cfg.ShortenLinks = &shorten
+	}
+
 	return cfg, nil
 }
 
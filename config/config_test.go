@@ -2,9 +2,17 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+
+	"schneider.vip/share/secret"
 )
 
 func TestMigrateV1(t *testing.T) {
@@ -46,6 +54,33 @@ func TestMigrateV1(t *testing.T) {
 	}
 }
 
+func TestMigrateV1URLShortener(t *testing.T) {
+	v1JSON := `{
+		"provider": "dropbox",
+		"providersettings": {"token": "xyz"},
+		"urlshortenerprovider": "biturl",
+		"urlshortenersettings": {"apikey": "k"}
+	}`
+
+	cfg, err := migrateV1([]byte(v1JSON), "/tmp/test.json")
+	if err != nil {
+		t.Fatalf("migrateV1: %v", err)
+	}
+
+	if cfg.Shortener == nil {
+		t.Fatal("expected shortener to be migrated")
+	}
+	if cfg.Shortener.Type != "biturl" {
+		t.Errorf("expected shortener type=biturl, got %q", cfg.Shortener.Type)
+	}
+	if cfg.Shortener.Settings["apikey"] != "k" {
+		t.Errorf("expected apikey=k, got %q", cfg.Shortener.Settings["apikey"])
+	}
+	if !cfg.ShortenLinksEnabled() {
+		t.Error("expected shortening to stay enabled")
+	}
+}
+
 func TestMigrateV1Empty(t *testing.T) {
 	v1JSON := `{}`
 
@@ -63,6 +98,9 @@ func TestMigrateV1Empty(t *testing.T) {
 	if len(cfg.Providers) != 0 {
 		t.Fatalf("expected 0 providers, got %d", len(cfg.Providers))
 	}
+	if cfg.Shortener != nil || cfg.ShortenLinksEnabled() {
+		t.Errorf("expected no shortener, got %+v", cfg.Shortener)
+	}
 }
 
 func TestLoadConfigV2(t *testing.T) {
@@ -186,3 +224,199 @@ func TestConfigHelpers(t *testing.T) {
 		t.Errorf("unexpected labels: %v", labels)
 	}
 }
+
+func TestProviderLabelsUnique(t *testing.T) {
+	cfg := &Config{Version: 2, Providers: []ProviderEntry{}}
+	if err := cfg.AddProvider(ProviderEntry{Label: "a", Type: "webdav"}); err != nil {
+		t.Fatalf("AddProvider: %v", err)
+	}
+	if err := cfg.AddProvider(ProviderEntry{Label: "b", Type: "box"}); err != nil {
+		t.Fatalf("AddProvider: %v", err)
+	}
+	if err := cfg.AddProvider(ProviderEntry{Label: "a", Type: "box"}); err == nil {
+		t.Error("AddProvider should fail for a duplicate label")
+	}
+	if err := cfg.AddProvider(ProviderEntry{Label: " ", Type: "box"}); err == nil {
+		t.Error("AddProvider should fail for an empty label")
+	}
+	if len(cfg.Providers) != 2 {
+		t.Fatalf("expected 2 providers, got %d", len(cfg.Providers))
+	}
+
+	cfg.Active = "a"
+	if err := cfg.RenameProvider("a", "b"); err == nil {
+		t.Error("RenameProvider should fail for a taken label")
+	}
+	if err := cfg.RenameProvider("missing", "c"); err == nil {
+		t.Error("RenameProvider should fail for a nonexistent label")
+	}
+	if err := cfg.RenameProvider("a", "c"); err != nil {
+		t.Fatalf("RenameProvider: %v", err)
+	}
+	if cfg.Active != "c" || cfg.FindByLabel("c") == nil || cfg.FindByLabel("a") != nil {
+		t.Errorf("after rename: active=%q labels=%v", cfg.Active, cfg.ProviderLabels())
+	}
+	if err := cfg.RenameProvider("c", "c"); err != nil {
+		t.Errorf("RenameProvider to the same label: %v", err)
+	}
+}
+
+// refresh stores a new token for label and increments the shared counter,
+// like the token refresh callback of one share process.
+func refresh(cfg *Config, label, token string) error {
+	return cfg.Update(func(fresh *Config) error {
+		e := fresh.FindByLabel(label)
+		if e == nil {
+			return fmt.Errorf("provider %q not found", label)
+		}
+		e.Settings["token"] = token
+		counter := fresh.FindByLabel("counter")
+		counter.Settings["n"] = strconv.Itoa(atoi(counter.Settings["n"]) + 1)
+		return nil
+	})
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func writeTestConfig(t *testing.T, secretStore string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.SecretStore = secretStore
+	for _, label := range []string{"a", "b", "counter"} {
+		cfg.AddProvider(ProviderEntry{Label: label, Type: "dropbox", Settings: map[string]string{}})
+	}
+	if err := cfg.Write(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func checkRefreshes(t *testing.T, path string, n int) {
+	t.Helper()
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, label := range []string{"a", "b"} {
+		if err := cfg.ResolveSecrets(cfg.FindByLabel(label)); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := cfg.FindByLabel(label).Settings["token"], fmt.Sprintf("%s-%d", label, n-1); got != want {
+			t.Errorf("token of %s = %q, want %q", label, got, want)
+		}
+	}
+	if got := cfg.FindByLabel("counter").Settings["n"]; got != strconv.Itoa(2*n) {
+		t.Errorf("counter = %s, want %d: updates were lost", got, 2*n)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			t.Errorf("temporary file left: %s", e.Name())
+		}
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	t.Setenv(secret.PassphraseEnv, "correct horse")
+	// With a file store, the tokens go to one encrypted file shared by all.
+	for _, store := range []string{"", secret.File} {
+		t.Run("store="+store, func(t *testing.T) {
+			path := writeTestConfig(t, store)
+			const n = 50
+			var wg sync.WaitGroup
+			for _, label := range []string{"a", "b"} {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					// Each goroutine has its own config, like a separate process.
+					cfg, err := LoadConfig(path)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					for i := range n {
+						if err := refresh(cfg, label, fmt.Sprintf("%s-%d", label, i)); err != nil {
+							t.Error(err)
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			checkRefreshes(t, path, n)
+		})
+	}
+}
+
+// TestConcurrentUpdatesProcesses runs the refreshes in two processes; the
+// helper mode of the test binary is selected by SHARECMD_TEST_REFRESH.
+func TestConcurrentUpdatesProcesses(t *testing.T) {
+	if label := os.Getenv("SHARECMD_TEST_REFRESH"); label != "" {
+		cfg, err := LoadConfig(os.Getenv("SHARECMD_TEST_CONFIG"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range atoi(os.Getenv("SHARECMD_TEST_N")) {
+			if err := refresh(cfg, label, fmt.Sprintf("%s-%d", label, i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+
+	path := writeTestConfig(t, "")
+	const n = 30
+	var cmds []*exec.Cmd
+	for _, label := range []string{"a", "b"} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestConcurrentUpdatesProcesses$")
+		cmd.Env = append(os.Environ(),
+			"SHARECMD_TEST_REFRESH="+label,
+			"SHARECMD_TEST_CONFIG="+path,
+			"SHARECMD_TEST_N="+strconv.Itoa(n))
+		out := new(strings.Builder)
+		cmd.Stdout, cmd.Stderr = out, out
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		cmds = append(cmds, cmd)
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("%v\n%s", err, cmd.Stdout)
+		}
+	}
+	checkRefreshes(t, path, n)
+}
+
+func TestWriteReplacesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	cfg, _ := LoadConfig(path)
+	cfg.AddProvider(ProviderEntry{Label: "x", Type: "box", Settings: map[string]string{"token": "t"}})
+	if err := cfg.Write(); err != nil {
+		t.Fatal(err)
+	}
+	// Readers holding the old file keep seeing complete content.
+	old, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	cfg.RemoveProvider("x")
+	if err := cfg.Write(); err != nil {
+		t.Fatal(err)
+	}
+	var before Config
+	if err := json.NewDecoder(old).Decode(&before); err != nil || len(before.Providers) != 1 {
+		t.Errorf("old file changed: %+v, %v", before, err)
+	}
+	if fi, _ := os.Stat(path); runtime.GOOS != "windows" && fi.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", fi.Mode().Perm())
+	}
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Environment variables of an ad-hoc provider. Its settings are read from
+// SHARECMD_<SETTING>, see EnvName.
+const (
+	EnvPrefix        = "SHARECMD_"
+	EnvProviderType  = EnvPrefix + "PROVIDER_TYPE"
+	EnvProviderLabel = EnvPrefix + "PROVIDER_LABEL"
+)
+
+// envReserved are SHARECMD_ variables that are not settings.
+var envReserved = []string{EnvProviderType, EnvProviderLabel, EnvPrefix + "SECRET_PASSPHRASE"}
+
+// EnvName returns the environment variable of a setting: the key in upper
+// snake case, e.g. SHARECMD_SECRET_ACCESS_KEY for secretAccessKey.
+func EnvName(key string) string {
+	r := []rune(key)
+	var b strings.Builder
+	b.WriteString(EnvPrefix)
+	for i, c := range r {
+		if i > 0 && unicode.IsUpper(c) &&
+			(unicode.IsLower(r[i-1]) || i+1 < len(r) && unicode.IsLower(r[i+1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(c))
+	}
+	return b.String()
+}
+
+// AdHocProvider returns a provider that is not in the config file: of type
+// typ, or SHARECMD_PROVIDER_TYPE if typ is empty, with the settings from
+// SHARECMD_<SETTING> variables in environ. keys lists the settings of each
+// provider type. It returns nil if no type is given.
+func AdHocProvider(typ string, environ []string, keys map[string][]string) (*ProviderEntry, error) {
+	env := map[string]string{}
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(k, EnvPrefix) {
+			env[k] = v
+		}
+	}
+	if typ == "" {
+		typ = env[EnvProviderType]
+	}
+	if typ == "" {
+		return nil, nil
+	}
+	known, ok := keys[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider type %q", typ)
+	}
+
+	entry := &ProviderEntry{Label: env[EnvProviderLabel], Type: typ, Settings: map[string]string{}}
+	if entry.Label == "" {
+		entry.Label = typ
+	}
+	byEnv := map[string]string{}
+	for _, key := range known {
+		byEnv[EnvName(key)] = key
+	}
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		key, ok := byEnv[name]
+		if !ok {
+			if slices.Contains(envReserved, name) {
+				continue
+			}
+			return nil, fmt.Errorf("%s is not a setting of %s providers, expecting one of %s", name, typ, envNames(known))
+		}
+		entry.Settings[key] = env[name]
+	}
+	return entry, nil
+}
+
+func envNames(keys []string) string {
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = EnvName(key)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Override sets the "key=value" pairs in the settings of e, which must be
+// among keys, the settings of its type. The config on disk is not changed.
+func (e *ProviderEntry) Override(pairs []string, keys []string) error {
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid setting %q, expecting key=value", pair)
+		}
+		if !slices.Contains(keys, key) {
+			return fmt.Errorf("%s is not a setting of %s providers, expecting one of %s", key, e.Type, strings.Join(keys, ", "))
+		}
+		if e.Settings == nil {
+			e.Settings = map[string]string{}
+		}
+		e.Settings[key] = value
+	}
+	return nil
+}
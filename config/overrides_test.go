@@ -0,0 +1,85 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+var testKeys = map[string][]string{
+	"nextcloud": {"url", "username", "password", "linkShareWithPassword"},
+	"s3":        {"bucket", "accessKeyID", "secretAccessKey", "pathStyle"},
+}
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"url":                   "SHARECMD_URL",
+		"linkShareWithPassword": "SHARECMD_LINK_SHARE_WITH_PASSWORD",
+		"accessKeyID":           "SHARECMD_ACCESS_KEY_ID",
+		"disableEPSV":           "SHARECMD_DISABLE_EPSV",
+		"apiURL":                "SHARECMD_API_URL",
+		"googletoken":           "SHARECMD_GOOGLETOKEN",
+	}
+	for key, want := range tests {
+		if got := EnvName(key); got != want {
+			t.Errorf("EnvName(%s) = %s, want %s", key, got, want)
+		}
+	}
+}
+
+func TestAdHocProvider(t *testing.T) {
+	environ := []string{
+		"HOME=/root",
+		"SHARECMD_PROVIDER_TYPE=nextcloud",
+		"SHARECMD_URL=https://nc.example",
+		"SHARECMD_USERNAME=me",
+		"SHARECMD_LINK_SHARE_WITH_PASSWORD=true",
+		"SHARECMD_SECRET_PASSPHRASE=x",
+	}
+	entry, err := AdHocProvider("", environ, testKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Label != "nextcloud" || entry.Type != "nextcloud" || len(entry.Settings) != 3 ||
+		entry.Settings["url"] != "https://nc.example" || entry.Settings["linkShareWithPassword"] != "true" {
+		t.Errorf("entry = %+v", entry)
+	}
+
+	// --set overrides and adds settings.
+	if err := entry.Override([]string{"username=you", "password=a=b"}, testKeys["nextcloud"]); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Settings["username"] != "you" || entry.Settings["password"] != "a=b" {
+		t.Errorf("settings = %v", entry.Settings)
+	}
+
+	// The type flag wins over the environment, whose settings must fit it.
+	if _, err := AdHocProvider("s3", environ, testKeys); err == nil || !strings.Contains(err.Error(), "is not a setting of s3") {
+		t.Errorf("err = %v", err)
+	}
+	entry, err = AdHocProvider("s3", []string{"SHARECMD_PROVIDER_LABEL=ci", "SHARECMD_ACCESS_KEY_ID=AK"}, testKeys)
+	if err != nil || entry.Label != "ci" || entry.Settings["accessKeyID"] != "AK" {
+		t.Errorf("entry = %+v, %v", entry, err)
+	}
+
+	if entry, err := AdHocProvider("", []string{"SHARECMD_URL=x"}, testKeys); entry != nil || err != nil {
+		t.Errorf("without type: %+v, %v", entry, err)
+	}
+	if _, err := AdHocProvider("ftp", nil, testKeys); err == nil {
+		t.Error("unknown type accepted")
+	}
+}
+
+func TestOverride(t *testing.T) {
+	entry := &ProviderEntry{Label: "x", Type: "s3"}
+	for _, pairs := range [][]string{{"bucket"}, {"=x"}, {"region=eu"}} {
+		if err := entry.Override(pairs, testKeys["s3"]); err == nil {
+			t.Errorf("Override(%v) accepted", pairs)
+		}
+	}
+	if err := entry.Override([]string{"bucket=b", "pathStyle="}, testKeys["s3"]); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := entry.Settings["pathStyle"]; !ok || v != "" || entry.Settings["bucket"] != "b" {
+		t.Errorf("settings = %v", entry.Settings)
+	}
+}
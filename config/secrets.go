@@ -0,0 +1,251 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+
+	"schneider.vip/share/secret"
+)
+
+// SecretKeys are the provider and URL shortener settings holding
+// credentials. With a SecretStore they are kept in the store and the config
+// file only holds references to them. The headers of the HTTP upload, paste
+// and json shortener types are included as a whole, since they usually
+// carry an Authorization token.
+var SecretKeys = []string{
+	"token", "googletoken", "password", "pass", "passphrase",
+	"secretAccessKey", "accountKey", "connectionString",
+	"apikey", "signature", "headers",
+}
+
+// storedSecret remembers which secret a resolved setting came from, so that
+// a changed value replaces it instead of creating a new one.
+type storedSecret struct {
+	id    string
+	value string
+}
+
+// secretStore opens the configured store on first use.
+func (c *Config) secretStore() (secret.Store, error) {
+	if c.store != nil {
+		return c.store, nil
+	}
+	if c.SecretStore == "" {
+		return nil, errors.New("settings refer to secrets, but no secret_store is configured")
+	}
+	store, err := secret.Open(c.SecretStore, path.Dir(c.Path))
+	if err != nil {
+		return nil, err
+	}
+	c.store = store
+	return store, nil
+}
+
+// ResolveSecrets replaces the secret references in the settings of entry
+// with the secrets themselves. Entries that are not resolved keep their
+// references when the config is written.
+func (c *Config) ResolveSecrets(entry *ProviderEntry) error {
+	return c.resolve(entry.Settings, &entry.secrets, fmt.Sprintf("provider %q", entry.Label))
+}
+
+// ResolveShortenerSecrets does what ResolveSecrets does for the settings of
+// the URL shortener, if one is configured.
+func (c *Config) ResolveShortenerSecrets() error {
+	if c.Shortener == nil {
+		return nil
+	}
+	return c.resolve(c.Shortener.Settings, &c.Shortener.secrets, "the URL shortener")
+}
+
+// resolve replaces the references in settings with the secrets and records
+// them in stored; owner names the settings in errors.
+func (c *Config) resolve(settings map[string]string, stored *map[string]storedSecret, owner string) error {
+	for key, value := range settings {
+		id, ok := secret.ParseRef(value)
+		if !ok {
+			continue
+		}
+		store, err := c.secretStore()
+		if err != nil {
+			return err
+		}
+		v, err := store.Get(id)
+		if err != nil {
+			return fmt.Errorf("secret %s of %s: %w", key, owner, err)
+		}
+		settings[key] = v
+		if *stored == nil {
+			*stored = map[string]storedSecret{}
+		}
+		(*stored)[key] = storedSecret{id: id, value: v}
+	}
+	return nil
+}
+
+// externalize moves the plaintext secrets of all providers and the URL
+// shortener into the secret store and returns a copy of c to write to disk,
+// which holds references instead. Without a SecretStore it returns c.
+func (c *Config) externalize() (*Config, error) {
+	if c.SecretStore == "" {
+		return c, nil
+	}
+	disk := *c
+	disk.Providers = make([]ProviderEntry, len(c.Providers))
+	for i := range c.Providers {
+		entry := &c.Providers[i]
+		settings, err := c.externalizeSettings(entry.Settings, &entry.secrets, fmt.Sprintf("provider %q", entry.Label))
+		if err != nil {
+			return nil, err
+		}
+		disk.Providers[i] = ProviderEntry{Label: entry.Label, Type: entry.Type, Settings: settings}
+	}
+	if c.Shortener != nil {
+		settings, err := c.externalizeSettings(c.Shortener.Settings, &c.Shortener.secrets, "the URL shortener")
+		if err != nil {
+			return nil, err
+		}
+		disk.Shortener = &ShortenerEntry{Type: c.Shortener.Type, Settings: settings}
+	}
+	return &disk, nil
+}
+
+// externalizeSettings stores the SecretKeys of settings that are not
+// references yet, updating stored, and returns a copy of settings with
+// references in their place; owner names the settings in errors.
+func (c *Config) externalizeSettings(settings map[string]string, stored *map[string]storedSecret, owner string) (map[string]string, error) {
+	disk := maps.Clone(settings)
+	for _, key := range SecretKeys {
+		value := disk[key]
+		old, known := (*stored)[key]
+		if value == "" {
+			// Cleared in setup: the secret is no longer needed.
+			if known {
+				c.removed = append(c.removed, old.id)
+				delete(*stored, key)
+			}
+			continue
+		}
+		if _, ok := secret.ParseRef(value); ok {
+			continue
+		}
+		if !known || old.value != value {
+			store, err := c.secretStore()
+			if err != nil {
+				return nil, err
+			}
+			if !known {
+				if old.id, err = secret.NewID(); err != nil {
+					return nil, err
+				}
+			}
+			if err := store.Set(old.id, value); err != nil {
+				return nil, fmt.Errorf("failed to store %s of %s: %w", key, owner, err)
+			}
+			old.value = value
+			if *stored == nil {
+				*stored = map[string]storedSecret{}
+			}
+			(*stored)[key] = old
+		}
+		disk[key] = secret.Ref(old.id)
+	}
+	return disk, nil
+}
+
+// trackSecrets remembers the secrets settings refer to in stored, so that a
+// new value set without resolving them first replaces the old secret.
+func trackSecrets(settings map[string]string, stored *map[string]storedSecret) {
+	for key, value := range settings {
+		id, ok := secret.ParseRef(value)
+		if !ok {
+			continue
+		}
+		if *stored == nil {
+			*stored = map[string]storedSecret{}
+		}
+		// The value is unknown until resolved, so any new one is stored.
+		(*stored)[key] = storedSecret{id: id}
+	}
+}
+
+// secretIDs returns the IDs of all secrets settings refer to, resolved or
+// not.
+func secretIDs(settings map[string]string, stored map[string]storedSecret) []string {
+	var ids []string
+	for key, value := range settings {
+		if id, ok := secret.ParseRef(value); ok {
+			ids = append(ids, id)
+		} else if old, ok := stored[key]; ok {
+			ids = append(ids, old.id)
+		}
+	}
+	return ids
+}
+
+// deleteRemoved deletes the secrets of removed providers after the config
+// no longer refers to them. Failures only leave unused secrets behind.
+func (c *Config) deleteRemoved() {
+	if len(c.removed) == 0 {
+		return
+	}
+	store, err := c.secretStore()
+	if err != nil {
+		return
+	}
+	for _, id := range c.removed {
+		store.Delete(id)
+	}
+	c.removed = nil
+}
+
+// MigrateSecrets moves the secrets of all providers into the store described
+// by spec (see secret.Open), or back into the config file if spec is empty,
+// and writes the config. Plaintext secrets already in the file are moved as
+// well.
+func (c *Config) MigrateSecrets(spec string) error {
+	if spec != "" {
+		if _, err := secret.Open(spec, path.Dir(c.Path)); err != nil {
+			return err
+		}
+	}
+	if spec == c.SecretStore {
+		// Write moves plaintext secrets into the store.
+		return c.Write()
+	}
+	for i := range c.Providers {
+		if err := c.ResolveSecrets(&c.Providers[i]); err != nil {
+			return err
+		}
+	}
+	if err := c.ResolveShortenerSecrets(); err != nil {
+		return err
+	}
+
+	oldStore := c.store
+	var oldIDs []string
+	for i := range c.Providers {
+		entry := &c.Providers[i]
+		oldIDs = append(oldIDs, secretIDs(entry.Settings, entry.secrets)...)
+		entry.secrets = nil
+	}
+	if c.Shortener != nil {
+		oldIDs = append(oldIDs, secretIDs(c.Shortener.Settings, c.Shortener.secrets)...)
+		c.Shortener.secrets = nil
+	}
+	c.SecretStore = spec
+	c.store = nil
+	// Secrets of removed providers are still in the old store.
+	oldIDs = append(oldIDs, c.removed...)
+	c.removed = nil
+	if err := c.Write(); err != nil {
+		return err
+	}
+	if oldStore != nil {
+		for _, id := range oldIDs {
+			oldStore.Delete(id)
+		}
+	}
+	return nil
+}
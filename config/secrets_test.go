@@ -0,0 +1,211 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"schneider.vip/share/secret"
+)
+
+// memStore is an in-memory secret.Store.
+type memStore map[string]string
+
+func (m memStore) Get(id string) (string, error) {
+	v, ok := m[id]
+	if !ok {
+		return "", secret.ErrNotFound
+	}
+	return v, nil
+}
+
+func (m memStore) Set(id, value string) error {
+	m[id] = value
+	return nil
+}
+
+func (m memStore) Delete(id string) error {
+	delete(m, id)
+	return nil
+}
+
+// load reads the config at path with store as its secret store.
+func load(t *testing.T, path string, store secret.Store) *Config {
+	t.Helper()
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.store = store
+	return cfg
+}
+
+func TestSecretsInStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	store := memStore{}
+	cfg := load(t, path, store)
+	cfg.SecretStore = secret.Keyring
+	cfg.AddProvider(ProviderEntry{Label: "dav", Type: "webdav", Settings: map[string]string{
+		"url": "https://dav.example", "username": "me", "password": "hunter2",
+	}})
+	if err := cfg.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Providers[0].Settings["password"] != "hunter2" {
+		t.Error("Write changed the settings in memory")
+	}
+
+	content, _ := os.ReadFile(path)
+	if strings.Contains(string(content), "hunter2") {
+		t.Fatalf("secret written to config file:\n%s", content)
+	}
+	if len(store) != 1 {
+		t.Fatalf("store = %v", store)
+	}
+
+	cfg = load(t, path, store)
+	entry := cfg.FindByLabel("dav")
+	ref := entry.Settings["password"]
+	if _, ok := secret.ParseRef(ref); !ok || entry.Settings["url"] != "https://dav.example" {
+		t.Fatalf("settings = %v", entry.Settings)
+	}
+	if err := cfg.ResolveSecrets(entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Settings["password"] != "hunter2" {
+		t.Errorf("resolved password = %q", entry.Settings["password"])
+	}
+
+	// A changed secret, like a refreshed token, replaces the stored one.
+	entry.Settings["password"] = "hunter3"
+	if err := cfg.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if len(store) != 1 {
+		t.Errorf("store = %v", store)
+	}
+	cfg = load(t, path, store)
+	if cfg.Providers[0].Settings["password"] != ref {
+		t.Errorf("reference changed to %s", cfg.Providers[0].Settings["password"])
+	}
+
+	// Unresolved entries keep their references.
+	cfg.AddProvider(ProviderEntry{Label: "box", Type: "box", Settings: map[string]string{"token": "{}"}})
+	if err := cfg.Write(); err != nil {
+		t.Fatal(err)
+	}
+	cfg = load(t, path, store)
+	if cfg.Providers[0].Settings["password"] != ref || len(store) != 2 {
+		t.Errorf("settings = %v, store = %v", cfg.Providers[0].Settings, store)
+	}
+
+	cfg.RemoveProvider("dav")
+	cfg.RemoveProvider("box")
+	if err := cfg.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if len(store) != 0 {
+		t.Errorf("secrets of removed providers left: %v", store)
+	}
+}
+
+func TestShortenerSecretsInStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	store := memStore{}
+	cfg := load(t, path, store)
+	cfg.SecretStore = secret.Keyring
+	cfg.Shortener = &ShortenerEntry{Type: "shlink", Settings: map[string]string{"url": "https://s.example", "apikey": "k1"}}
+	cfg.AddProvider(ProviderEntry{Label: "paste", Type: "paste", Settings: map[string]string{
+		"url": "https://paste.example", "headers": `{"Authorization":"Bearer t"}`,
+	}})
+	if err := cfg.Write(); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(path)
+	if strings.Contains(string(content), "k1") || strings.Contains(string(content), "Bearer") {
+		t.Fatalf("secret written to config file:\n%s", content)
+	}
+	if len(store) != 2 {
+		t.Fatalf("store = %v", store)
+	}
+
+	cfg = load(t, path, store)
+	ref := cfg.Shortener.Settings["apikey"]
+	if err := cfg.ResolveShortenerSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Shortener.Settings["apikey"] != "k1" || cfg.Shortener.Settings["url"] != "https://s.example" {
+		t.Errorf("resolved settings = %v", cfg.Shortener.Settings)
+	}
+
+	// A new key replaces the stored one, and clearing it deletes it.
+	cfg.Shortener.Settings["apikey"] = "k2"
+	if err := cfg.Write(); err != nil {
+		t.Fatal(err)
+	}
+	cfg = load(t, path, store)
+	id, _ := secret.ParseRef(ref)
+	if cfg.Shortener.Settings["apikey"] != ref || store[id] != "k2" {
+		t.Errorf("apikey = %s, store = %v", cfg.Shortener.Settings["apikey"], store)
+	}
+	cfg.Shortener.Settings = map[string]string{}
+	if err := cfg.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if len(store) != 1 {
+		t.Errorf("store = %v", store)
+	}
+}
+
+func TestMigrateSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	// A config from before secret stores, with plaintext secrets.
+	cfg := load(t, path, nil)
+	cfg.AddProvider(ProviderEntry{Label: "nc", Type: "nextcloud", Settings: map[string]string{"url": "https://nc.example", "password": "pw"}})
+	cfg.AddProvider(ProviderEntry{Label: "db", Type: "dropbox", Settings: map[string]string{"token": `{"access_token":"at"}`}})
+	cfg.Shortener = &ShortenerEntry{Type: "kutt", Settings: map[string]string{"apikey": "kuttkey"}}
+	if err := cfg.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(secret.PassphraseEnv, "test passphrase")
+	cfg = load(t, path, nil)
+	if err := cfg.MigrateSecrets(secret.File); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(path)
+	if strings.Contains(string(content), "access_token") || strings.Contains(string(content), `"pw"`) || strings.Contains(string(content), "kuttkey") {
+		t.Fatalf("secrets left in config file:\n%s", content)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(path), secret.FileName)); err != nil {
+		t.Fatal(err)
+	}
+
+	// And back into the config file.
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SecretStore != secret.File {
+		t.Fatalf("secret_store = %q", cfg.SecretStore)
+	}
+	if err := cfg.MigrateSecrets(""); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ = LoadConfig(path)
+	if cfg.SecretStore != "" || cfg.FindByLabel("db").Settings["token"] != `{"access_token":"at"}` || cfg.FindByLabel("nc").Settings["password"] != "pw" || cfg.Shortener.Settings["apikey"] != "kuttkey" {
+		t.Errorf("config after migrating back: %+v", cfg)
+	}
+
+	if err := cfg.MigrateSecrets("vault"); err == nil {
+		t.Error("unknown store accepted")
+	}
+}
+
+func TestResolveWithoutStore(t *testing.T) {
+	cfg := &Config{Providers: []ProviderEntry{{Label: "x", Settings: map[string]string{"token": secret.Ref("abc")}}}}
+	if err := cfg.ResolveSecrets(&cfg.Providers[0]); err == nil || !strings.Contains(err.Error(), "no secret_store") {
+		t.Errorf("err = %v", err)
+	}
+}
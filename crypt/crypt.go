@@ -0,0 +1,280 @@
+// Package crypt encrypts uploads on the client, so that providers only ever
+// store ciphertext.
+//
+// The format is a STREAM construction with AES-256-GCM: a 16 byte header
+// (magic, version and a random nonce prefix) followed by chunks of 64 KiB
+// plaintext, each sealed with its own nonce made of the prefix, a chunk
+// counter and a flag marking the last chunk. The last chunk is always shorter
+// than a full chunk (possibly empty), so truncating or reordering the stream
+// is detected while decrypting.
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Ext is appended to the filename of encrypted uploads.
+const Ext = ".enc"
+
+// KeySize is the length of a key in bytes.
+const KeySize = 32
+
+const (
+	magic      = "SHARECMD"
+	version    = 1
+	prefixSize = 7
+	headerSize = len(magic) + 1 + prefixSize
+	chunkSize  = 64 * 1024
+	tagSize    = 16
+)
+
+// keyParam is the name of the URL fragment parameter holding the key.
+const keyParam = "key="
+
+// ErrDecrypt is returned when a chunk cannot be authenticated, i.e. the key
+// is wrong or the data was modified.
+var ErrDecrypt = errors.New("decryption failed: wrong key or corrupted data")
+
+// Key is a random AES-256 key.
+type Key [KeySize]byte
+
+// NewKey generates a random key.
+func NewKey() (Key, error) {
+	var k Key
+	_, err := rand.Read(k[:])
+	return k, err
+}
+
+// String encodes the key as unpadded base64url, so it can be used in a URL.
+func (k Key) String() string {
+	return base64.RawURLEncoding.EncodeToString(k[:])
+}
+
+// ParseKey decodes a key produced by Key.String.
+func ParseKey(s string) (Key, error) {
+	var k Key
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) != KeySize {
+		return k, errors.New("invalid key")
+	}
+	copy(k[:], b)
+	return k, nil
+}
+
+// AddKey appends the key to link as URL fragment. Browsers and HTTP clients
+// never send the fragment, so the provider does not see the key.
+func AddKey(link string, k Key) string {
+	return link + "#" + keyParam + k.String()
+}
+
+// SplitLink separates a link created by AddKey into the download URL and the
+// key. ok is false if the link has no key.
+func SplitLink(link string) (url string, k Key, ok bool, err error) {
+	url, fragment, found := strings.Cut(link, "#")
+	if !found || !strings.HasPrefix(fragment, keyParam) {
+		return link, k, false, nil
+	}
+	k, err = ParseKey(strings.TrimPrefix(fragment, keyParam))
+	return url, k, err == nil, err
+}
+
+// EncryptedSize returns the length of the ciphertext for size bytes of
+// plaintext. A negative size (unknown length) is returned unchanged.
+func EncryptedSize(size int64) int64 {
+	if size < 0 {
+		return size
+	}
+	full := size / chunkSize
+	return int64(headerSize) + full*(chunkSize+tagSize) + size%chunkSize + tagSize
+}
+
+func newAEAD(k Key) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// stream holds the nonce state shared by encryption and decryption.
+type stream struct {
+	aead    cipher.AEAD
+	nonce   [12]byte
+	counter uint64
+}
+
+// next sets the nonce for the next chunk.
+func (s *stream) next(last bool) error {
+	if s.counter > math.MaxUint32 {
+		return errors.New("stream too long")
+	}
+	binary.BigEndian.PutUint32(s.nonce[prefixSize:], uint32(s.counter))
+	s.nonce[11] = 0
+	if last {
+		s.nonce[11] = 1
+	}
+	s.counter++
+	return nil
+}
+
+// encryptReader encrypts its source while it is read.
+type encryptReader struct {
+	stream
+	src   io.Reader
+	plain []byte
+	buf   []byte
+	out   []byte // ciphertext not yet returned
+	done  bool
+	err   error
+}
+
+// Encrypt returns a reader that yields the encrypted content of r.
+func Encrypt(r io.Reader, k Key) (io.Reader, error) {
+	aead, err := newAEAD(k)
+	if err != nil {
+		return nil, err
+	}
+	e := &encryptReader{
+		stream: stream{aead: aead},
+		src:    r,
+		plain:  make([]byte, chunkSize),
+		buf:    make([]byte, 0, chunkSize+tagSize),
+	}
+	if _, err := rand.Read(e.nonce[:prefixSize]); err != nil {
+		return nil, err
+	}
+	e.out = make([]byte, 0, headerSize)
+	e.out = append(e.out, magic...)
+	e.out = append(e.out, version)
+	e.out = append(e.out, e.nonce[:prefixSize]...)
+	return e, nil
+}
+
+func (e *encryptReader) Read(p []byte) (int, error) {
+	for len(e.out) == 0 {
+		if e.err != nil {
+			return 0, e.err
+		}
+		if e.done {
+			return 0, io.EOF
+		}
+		e.fill()
+	}
+	n := copy(p, e.out)
+	e.out = e.out[n:]
+	return n, nil
+}
+
+// fill seals the next chunk into out. A short read from the source ends the
+// stream with a final chunk.
+func (e *encryptReader) fill() {
+	n, err := io.ReadFull(e.src, e.plain)
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		e.err = err
+		return
+	}
+	if err := e.next(last); err != nil {
+		e.err = err
+		return
+	}
+	e.out = e.aead.Seal(e.buf[:0], e.nonce[:], e.plain[:n], nil)
+	e.done = last
+}
+
+// decryptReader decrypts and authenticates its source while it is read.
+type decryptReader struct {
+	stream
+	src   io.Reader
+	chunk []byte
+	out   []byte // plaintext not yet returned
+	done  bool
+	err   error
+}
+
+// Decrypt reads the header from r and returns a reader for the plaintext.
+// Read returns ErrDecrypt if the key is wrong or the data was modified or
+// truncated; data is only returned after its chunk was authenticated.
+func Decrypt(r io.Reader, k Key) (io.Reader, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errors.New("not an encrypted file")
+		}
+		return nil, err
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, errors.New("not an encrypted file")
+	}
+	if v := header[len(magic)]; v != version {
+		return nil, fmt.Errorf("unsupported encryption version %d", v)
+	}
+	aead, err := newAEAD(k)
+	if err != nil {
+		return nil, err
+	}
+	d := &decryptReader{
+		stream: stream{aead: aead},
+		src:    r,
+		chunk:  make([]byte, chunkSize+tagSize),
+	}
+	copy(d.nonce[:], header[len(magic)+1:])
+	return d, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.done {
+			return 0, io.EOF
+		}
+		d.fill()
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+func (d *decryptReader) fill() {
+	n, err := io.ReadFull(d.src, d.chunk)
+	last := false
+	switch {
+	case err == io.ErrUnexpectedEOF:
+		last = true
+	case err == io.EOF:
+		// A full chunk must be followed by at least the final chunk.
+		d.err = fmt.Errorf("%w: stream truncated", ErrDecrypt)
+		return
+	case err != nil:
+		d.err = err
+		return
+	}
+	if n < tagSize {
+		d.err = fmt.Errorf("%w: stream truncated", ErrDecrypt)
+		return
+	}
+	if err := d.next(last); err != nil {
+		d.err = err
+		return
+	}
+	d.out, err = d.aead.Open(d.chunk[:0], d.nonce[:], d.chunk[:n], nil)
+	if err != nil {
+		d.err = ErrDecrypt
+		return
+	}
+	d.done = last
+}
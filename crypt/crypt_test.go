@@ -0,0 +1,104 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func encrypt(t *testing.T, plain []byte, k Key) []byte {
+	t.Helper()
+	r, err := Encrypt(bytes.NewReader(plain), k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc
+}
+
+func decrypt(enc []byte, k Key) ([]byte, error) {
+	r, err := Decrypt(bytes.NewReader(enc), k)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	k, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3 * chunkSize} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		enc := encrypt(t, plain, k)
+		if got := EncryptedSize(int64(size)); got != int64(len(enc)) {
+			t.Errorf("size %d: EncryptedSize = %d, ciphertext is %d bytes", size, got, len(enc))
+		}
+		got, err := decrypt(enc, k)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: plaintext differs after round trip", size)
+		}
+	}
+}
+
+func TestDecryptRejects(t *testing.T) {
+	k, _ := NewKey()
+	other, _ := NewKey()
+	plain := make([]byte, 2*chunkSize+100)
+	enc := encrypt(t, plain, k)
+
+	modified := bytes.Clone(enc)
+	modified[headerSize+10] ^= 1
+
+	tests := map[string]struct {
+		enc []byte
+		key Key
+	}{
+		"wrong key":            {enc, other},
+		"modified":             {modified, k},
+		"truncated in chunk":   {enc[:len(enc)-50], k},
+		"truncated at chunk":   {enc[:headerSize+chunkSize+tagSize], k},
+		"missing final chunk":  {enc[:headerSize+2*(chunkSize+tagSize)], k},
+		"appended final chunk": {append(bytes.Clone(enc), enc[len(enc)-116:]...), k},
+	}
+	for name, tt := range tests {
+		if _, err := decrypt(tt.enc, tt.key); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("%s: err = %v, want ErrDecrypt", name, err)
+		}
+	}
+
+	if _, err := decrypt([]byte("<html>not found</html>"), k); err == nil || errors.Is(err, ErrDecrypt) {
+		t.Errorf("plain data: err = %v, want format error", err)
+	}
+}
+
+func TestLinkKey(t *testing.T) {
+	k, _ := NewKey()
+	link := AddKey("https://example.com/s/abc?dl=1", k)
+
+	url, got, ok, err := SplitLink(link)
+	if err != nil || !ok {
+		t.Fatalf("SplitLink(%q) = ok %v, err %v", link, ok, err)
+	}
+	if url != "https://example.com/s/abc?dl=1" || got != k {
+		t.Errorf("SplitLink(%q) = %q, %v", link, url, got)
+	}
+
+	if _, _, ok, _ := SplitLink("https://example.com/page#section"); ok {
+		t.Error("link without key reported a key")
+	}
+	if _, _, _, err := SplitLink("https://example.com/#key=short"); err == nil {
+		t.Error("invalid key accepted")
+	}
+}
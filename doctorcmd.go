@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"schneider.vip/share/config"
+	"schneider.vip/share/provider"
+	"schneider.vip/share/tui"
+)
+
+// checkTimeout bounds the check of a single provider.
+const checkTimeout = 30 * time.Second
+
+// DoctorCmd checks the settings and credentials of the providers.
+type DoctorCmd struct {
+	Label string `arg:"" optional:"" help:"Only check the provider with this label."`
+}
+
+// Run checks each provider in turn and prints a line per provider. It fails
+// if any check failed.
+func (c *DoctorCmd) Run(g *Globals) error {
+	cfg, err := config.LookupConfig(g.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	entries := make([]*config.ProviderEntry, 0, len(cfg.Providers))
+	if c.Label != "" {
+		entry := cfg.FindByLabel(c.Label)
+		if entry == nil {
+			return fmt.Errorf("provider %q not found", c.Label)
+		}
+		entries = append(entries, entry)
+	} else {
+		for i := range cfg.Providers {
+			entries = append(entries, &cfg.Providers[i])
+		}
+	}
+	if len(entries) == 0 {
+		fmt.Println(tui.Subtle.Render("No providers configured."))
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	labelWidth, typeWidth := len("PROVIDER"), len("TYPE")
+	for _, entry := range entries {
+		labelWidth = max(labelWidth, len(entry.Label))
+		typeWidth = max(typeWidth, len(entry.Type))
+	}
+	fmt.Println(tui.Title.Render(fmt.Sprintf("%-*s  %-*s  %s", labelWidth, "PROVIDER", typeWidth, "TYPE", "RESULT")))
+
+	failed := 0
+	for _, entry := range entries {
+		fmt.Printf("%-*s  %-*s  ", labelWidth, entry.Label, typeWidth, entry.Type)
+		start := time.Now()
+		err := checkProvider(ctx, cfg, entry)
+		switch {
+		case errors.Is(err, provider.ErrUnsupported):
+			fmt.Println(tui.Subtle.Render("skipped (no check for this type)"))
+		case err != nil:
+			failed++
+			fmt.Printf("%s %v\n", tui.Error.Render("FAIL"), err)
+		default:
+			fmt.Printf("%s %s\n", tui.Success.Render("PASS"), tui.Subtle.Render(time.Since(start).Round(time.Millisecond).String()))
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d providers failed the check", failed, len(entries))
+	}
+	return nil
+}
+
+// checkProvider creates the provider of entry and runs its check within
+// checkTimeout. An OAuth token refreshed on the way is saved.
+func checkProvider(ctx context.Context, cfg *config.Config, entry *config.ProviderEntry) error {
+	prov, err := openProvider(cfg, entry)
+	if err != nil {
+		return err
+	}
+	if c, ok := prov.(io.Closer); ok {
+		defer c.Close()
+	}
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	return provider.Check(ctx, prov)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"regexp"
+	"strings"
+
+	"schneider.vip/share/crypt"
+	"schneider.vip/share/tui"
+)
+
+// GetCmd downloads and decrypts a file uploaded with --encrypt.
+type GetCmd struct {
+	Output string `help:"File to write, or - for stdout (default: name of the upload without .enc)." short:"o" placeholder:"PATH"`
+	Key    string `help:"Decryption key, if it is not part of the link."`
+	Link   string `arg:"" help:"Link printed by 'share --encrypt'."`
+}
+
+// Run downloads the link and writes the decrypted content. The output file is
+// removed again if the download or decryption fails.
+func (c *GetCmd) Run(g *Globals) error {
+	link, key, ok, err := crypt.SplitLink(c.Link)
+	if err != nil {
+		return fmt.Errorf("link: %w", err)
+	}
+	if c.Key != "" {
+		key, err = crypt.ParseKey(c.Key)
+		if err != nil {
+			return fmt.Errorf("--key: %w", err)
+		}
+	} else if !ok {
+		return errors.New("link has no decryption key (#key=...); pass it with --key")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", downloadURL(link), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	r, err := crypt.Decrypt(resp.Body, key)
+	if err != nil {
+		return fmt.Errorf("%w (the link must point to the file itself, not to a download page)", err)
+	}
+
+	if c.Output == "-" {
+		_, err = io.Copy(os.Stdout, r)
+		return err
+	}
+
+	name := c.Output
+	if name == "" {
+		name = downloadName(resp)
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name)
+		return err
+	}
+	fmt.Printf("%s %s (%s)\n", tui.Success.Render("Saved"), name, tui.HumanBytes(n))
+	return nil
+}
+
+var (
+	// nextcloudShare matches Nextcloud/Owncloud public share pages.
+	nextcloudShare = regexp.MustCompile(`/s/[A-Za-z0-9]+/?$`)
+	// driveFile matches Google Drive file view pages.
+	driveFile = regexp.MustCompile(`^https://drive\.google\.com/file/d/([^/]+)/`)
+)
+
+// downloadURL turns known share page links into direct download links.
+// Dropbox and Seafile links already are (?dl=1).
+func downloadURL(link string) string {
+	if m := driveFile.FindStringSubmatch(link); m != nil {
+		return "https://drive.google.com/uc?export=download&id=" + url.QueryEscape(m[1])
+	}
+	u, err := url.Parse(link)
+	if err == nil && u.RawQuery == "" && nextcloudShare.MatchString(u.Path) {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/download"
+		return u.String()
+	}
+	return link
+}
+
+// downloadName returns the filename for a download without the .enc suffix,
+// preferring the name sent by the server.
+func downloadName(resp *http.Response) string {
+	name := ""
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		name = params["filename"]
+	}
+	if name == "" {
+		name = path.Base(resp.Request.URL.Path)
+	}
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.TrimSuffix(name, crypt.Ext)
+	if name == "" || name == "." || name == "/" || name == ".." {
+		name = "download"
+	}
+	return name
+}
@@ -0,0 +1,186 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"schneider.vip/share/lockfile"
+)
+
+// Entry is a single successful upload.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Provider string    `json:"provider"`
+	Type     string    `json:"type"`
+	FileID   string    `json:"file_id"`
+	Name     string    `json:"name"`
+	Link     string    `json:"link"`
+	Size     int64     `json:"size"`
+	SHA256   string    `json:"sha256,omitempty"`
+	Removed  string    `json:"removed,omitempty"`
+	// Expires is when the link stops working; zero if it does not expire.
+	Expires time.Time `json:"expires,omitzero"`
+	// Protected is set if the link needs a password. The password itself is
+	// not recorded, since the history is a plaintext file.
+	Protected bool `json:"protected,omitempty"`
+	// Encrypted is set for uploads encrypted on the client. Link and
+	// LongLink are recorded without the key in their fragment.
+	Encrypted bool `json:"encrypted,omitempty"`
+	// LongLink is the link before it was shortened into Link.
+	LongLink string `json:"long_link,omitempty"`
+	// AdHoc is set if the provider came from the environment instead of
+	// the config; Provider is then only its label from the environment.
+	AdHoc bool `json:"adhoc,omitempty"`
+}
+
+// Values of Entry.Removed.
+const (
+	Deleted = "deleted"
+	Revoked = "revoked"
+)
+
+// Matches reports whether the query is contained (case-insensitive) in the
+// entry's name, link (short or long), provider label or type, or file ID.
+func (e *Entry) Matches(query string) bool {
+	q := strings.ToLower(query)
+	for _, field := range []string{e.Name, e.Link, e.LongLink, e.Provider, e.Type, e.FileID} {
+		if strings.Contains(strings.ToLower(field), q) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the index of the newest entry whose link (short or long) or
+// file ID equals linkOrID, or -1.
+func Find(entries []Entry, linkOrID string) int {
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := &entries[i]
+		if e.Link == linkOrID || e.FileID == linkOrID || e.LongLink != "" && e.LongLink == linkOrID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Store is an append-only JSON Lines file of upload entries.
+type Store struct {
+	Path string
+}
+
+// DefaultPath returns the history file that lives next to the config file.
+func DefaultPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "history.jsonl")
+}
+
+// Open returns the store at path. The file is created on first Append.
+func Open(path string) *Store {
+	return &Store{Path: path}
+}
+
+// lockPath is the file locked while the history is written. It is not the
+// history itself, which Update replaces.
+func (s *Store) lockPath() string {
+	return s.Path + ".lock"
+}
+
+// Append adds an entry to the end of the history.
+func (s *Store) Append(e Entry) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o700); err != nil {
+		return err
+	}
+	unlock, err := lockfile.Lock(s.lockPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Update loads the history, applies fn and replaces the history with the
+// entries it returns. The history is locked meanwhile, so entries appended
+// by other processes are not lost. The file is written to a temporary file
+// first and renamed, so a crash never truncates the history.
+func (s *Store) Update(fn func([]Entry) ([]Entry, error)) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o700); err != nil {
+		return err
+	}
+	unlock, err := lockfile.Lock(s.lockPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := s.Load()
+	if err != nil {
+		return err
+	}
+	if entries, err = fn(entries); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), ".history-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	enc := json.NewEncoder(tmp)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+// Load returns all entries, oldest first. A missing file is an empty history.
+func (s *Store) Load() ([]Entry, error) {
+	f, err := os.Open(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", s.Path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, sc.Err()
+}
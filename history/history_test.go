@@ -0,0 +1,132 @@
+package history
+
+import (
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAppendLoad(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "sub", "history.jsonl"))
+
+	entries, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load empty: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected empty history, got %d entries", len(entries))
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	s.Append(Entry{Time: now, Provider: "work-nc", Type: "nextcloud", FileID: "a.pdf", Name: "a.pdf", Link: "https://nc/s/1", Size: 3})
+	s.Append(Entry{Time: now, Provider: "db", Type: "dropbox", FileID: "/b.png", Name: "b.png", Link: "https://db/s/2", Size: 5})
+
+	entries, err = s.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[1].Link != "https://db/s/2" || !entries[0].Time.Equal(now) {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	e := Entry{Provider: "work-nc", Type: "nextcloud", Name: "Report.pdf", Link: "https://nc/s/abc"}
+	for _, q := range []string{"report", "WORK", "nextcloud", "s/abc"} {
+		if !e.Matches(q) {
+			t.Errorf("expected %q to match", q)
+		}
+	}
+	if e.Matches("dropbox") {
+		t.Error("expected dropbox not to match")
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	got := DefaultPath("/home/u/.config/sharecmd/config.json")
+	if got != filepath.Join("/home/u/.config/sharecmd", "history.jsonl") {
+		t.Errorf("unexpected path %q", got)
+	}
+}
+
+func TestFindAndUpdate(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "history.jsonl"))
+	s.Append(Entry{FileID: "1", Link: "https://x/1"})
+	s.Append(Entry{FileID: "2", Link: "https://x/2"})
+	s.Append(Entry{FileID: "1", Link: "https://x/1b"})
+	s.Append(Entry{FileID: "3", Link: "https://short/3", LongLink: "https://x/3"})
+
+	entries, _ := s.Load()
+	if i := Find(entries, "1"); i != 2 {
+		t.Errorf("expected newest match 2, got %d", i)
+	}
+	if i := Find(entries, "https://x/2"); i != 1 {
+		t.Errorf("expected 1, got %d", i)
+	}
+	if i := Find(entries, "https://x/3"); i != 3 {
+		t.Errorf("expected long link match 3, got %d", i)
+	}
+	if i := Find(entries, ""); i != -1 {
+		t.Errorf("expected no match for an empty long link, got %d", i)
+	}
+	if i := Find(entries, "nope"); i != -1 {
+		t.Errorf("expected -1, got %d", i)
+	}
+
+	err := s.Update(func(entries []Entry) ([]Entry, error) {
+		entries[1].Removed = Deleted
+		return entries, nil
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	entries, _ = s.Load()
+	if len(entries) != 4 || entries[1].Removed != Deleted {
+		t.Errorf("unexpected entries after update: %+v", entries)
+	}
+}
+
+func TestConcurrentAppendUpdate(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "history.jsonl"))
+	const n = 50
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range n {
+			if err := s.Append(Entry{FileID: strconv.Itoa(i)}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		// Like share rm, while uploads are recorded.
+		for range n {
+			err := s.Update(func(entries []Entry) ([]Entry, error) {
+				if len(entries) > 0 {
+					entries[0].Removed = Deleted
+				}
+				return entries, nil
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	entries, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != n {
+		t.Errorf("%d entries, want %d: appends were lost", len(entries), n)
+	}
+}
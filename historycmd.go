@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"schneider.vip/share/clipboard"
+	"schneider.vip/share/config"
+	"schneider.vip/share/crypt"
+	"schneider.vip/share/history"
+	"schneider.vip/share/tui"
+)
+
+// HistoryCmd groups the commands working on the upload history.
+type HistoryCmd struct {
+	List HistoryListCmd `cmd:"" default:"withargs" help:"List past uploads, optionally filtered (default)."`
+	Copy HistoryCopyCmd `cmd:"" help:"Copy the link of a past upload to the clipboard."`
+}
+
+// HistoryListCmd lists and searches the upload history.
+type HistoryListCmd struct {
+	Limit int      `help:"Show at most N entries (0 = all)." short:"n" default:"20"`
+	Query []string `arg:"" optional:"" help:"Only show uploads whose name, link, provider or file ID contains all of these words."`
+}
+
+// HistoryCopyCmd copies a link from the history to the clipboard.
+type HistoryCopyCmd struct {
+	Number int `arg:"" help:"Entry number as shown by 'share history'."`
+}
+
+// Run prints the matching entries, newest last.
+func (c *HistoryListCmd) Run(g *Globals) error {
+	entries, err := history.Open(history.DefaultPath(g.ConfigPath)).Load()
+	if err != nil {
+		return err
+	}
+
+	type numbered struct {
+		n int
+		e history.Entry
+	}
+	var matches []numbered
+	for i, e := range entries {
+		if matchesAll(&e, c.Query) {
+			matches = append(matches, numbered{n: i + 1, e: e})
+		}
+	}
+	if len(matches) == 0 {
+		fmt.Println(tui.Subtle.Render("No uploads found."))
+		return nil
+	}
+	if c.Limit > 0 && len(matches) > c.Limit {
+		matches = matches[len(matches)-c.Limit:]
+	}
+
+	for _, m := range matches {
+		removed := ""
+		if m.e.Removed != "" {
+			removed = " " + tui.Error.Render(m.e.Removed)
+		} else if !m.e.Expires.IsZero() && m.e.Expires.Before(time.Now()) {
+			removed = " " + tui.Error.Render("expired")
+		}
+		fmt.Printf("%s %s  %s %s%s\n    %s\n",
+			tui.Title.Render(fmt.Sprintf("#%d", m.n)),
+			m.e.Time.Local().Format(time.DateTime),
+			m.e.Name,
+			tui.Subtle.Render(fmt.Sprintf("(%s, %s, %s)", m.e.Provider, m.e.Type, tui.HumanBytes(m.e.Size))),
+			removed,
+			m.e.Link)
+	}
+	return nil
+}
+
+// Run copies the link of entry Number to the clipboard.
+func (c *HistoryCopyCmd) Run(g *Globals) error {
+	entries, err := history.Open(history.DefaultPath(g.ConfigPath)).Load()
+	if err != nil {
+		return err
+	}
+	if c.Number < 1 || c.Number > len(entries) {
+		return fmt.Errorf("no history entry #%d", c.Number)
+	}
+	e := entries[c.Number-1]
+	fmt.Printf("URL: %s\n", e.Link)
+	if e.Encrypted {
+		fmt.Println(tui.Subtle.Render("The key is not in the history; add the #key=... of the original link to download the file."))
+	}
+	if e.Protected {
+		fmt.Println(tui.Subtle.Render("The link is protected by a password, which is not in the history."))
+	}
+	clipboard.ToClip(e.Link)
+	return nil
+}
+
+func matchesAll(e *history.Entry, words []string) bool {
+	for _, w := range words {
+		if !e.Matches(w) {
+			return false
+		}
+	}
+	return true
+}
+
+// recordHistory appends all successful uploads to the history. Passwords and
+// the keys of encrypted uploads are left out, since the history is not
+// encrypted. adhoc marks a provider from the environment. Failures are only
+// logged, since the upload itself succeeded.
+func recordHistory(configPath string, entry *config.ProviderEntry, adhoc bool, results []*result) {
+	store := history.Open(history.DefaultPath(configPath))
+	now := time.Now().UTC()
+	for _, res := range results {
+		err := store.Append(history.Entry{
+			Time:      now,
+			Provider:  entry.Label,
+			Type:      entry.Type,
+			FileID:    res.fileID,
+			Name:      res.src.name,
+			Link:      withoutKey(res.link),
+			Size:      res.size,
+			SHA256:    res.checksum,
+			Expires:   res.expires,
+			Protected: res.password != "",
+			Encrypted: res.src.key != nil,
+			LongLink:  withoutKey(res.longLink),
+			AdHoc:     adhoc,
+		})
+		if err != nil {
+			log.Printf("Warning: failed to record upload history: %v\n", err)
+			return
+		}
+	}
+}
+
+// withoutKey returns link without the key of an encrypted upload.
+func withoutKey(link string) string {
+	url, _, _, _ := crypt.SplitLink(link)
+	return url
+}
@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package lockfile
+
+// Lock is a no-op on systems without file locks.
+func Lock(path string) (unlock func(), err error) {
+	return func() {}, nil
+}
@@ -0,0 +1,3 @@
+// Package lockfile serializes read-modify-write updates of files shared by
+// several share processes, like the config and the upload history.
+package lockfile
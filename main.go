@@ -1,50 +1,97 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/alecthomas/kong"
-	tea "github.com/charmbracelet/bubbletea"
-	"github.com/mdp/qrterminal/v3"
 	"github.com/spf13/cast"
 	"golang.org/x/oauth2"
-	"schneider.vip/share/clipboard"
 	"schneider.vip/share/config"
 	"schneider.vip/share/provider"
+	"schneider.vip/share/provider/azureblob"
 	"schneider.vip/share/provider/box"
 	"schneider.vip/share/provider/dropbox"
+	"schneider.vip/share/provider/ftp"
+	"schneider.vip/share/provider/gist"
 	"schneider.vip/share/provider/googledrive"
 	"schneider.vip/share/provider/httpupload"
 	"schneider.vip/share/provider/nextcloud"
 	"schneider.vip/share/provider/opendrive"
+	"schneider.vip/share/provider/paste"
+	"schneider.vip/share/provider/s3"
 	"schneider.vip/share/provider/seafile"
+	"schneider.vip/share/provider/sftp"
+	"schneider.vip/share/provider/webdav"
 	"schneider.vip/share/tui/setup"
-	"schneider.vip/share/tui/upload"
 )
 
 var version = "0.0.0"
 
+// randomPasswordLength is the length of passwords generated for
+// --random-password.
+const randomPasswordLength = 16
+
 type CLI struct {
-	Config  string   `help:"Path to config file (default: ${defaultConfigPath})." type:"path"`
-	Setup   bool     `help:"Launch interactive setup." short:"s"`
-	Select  bool     `help:"Select provider for this upload." short:"p"`
-	Version bool     `help:"Print version and exit." short:"v"`
-	Args    []string `arg:"" optional:"" help:"File to upload and optional provider name."`
+	Config  string `help:"Path to config file (default: ${defaultConfigPath})." type:"path"`
+	Version bool   `help:"Print version and exit." short:"v"`
+
+	Upload   UploadCmd   `cmd:"" default:"withargs" help:"Upload files and print share links (default)."`
+	History  HistoryCmd  `cmd:"" help:"List, search and re-copy links of past uploads."`
+	Rm       RmCmd       `cmd:"" help:"Delete an uploaded file or revoke its public link."`
+	Get      GetCmd      `cmd:"" help:"Download and decrypt a file shared with --encrypt."`
+	Secrets  SecretsCmd  `cmd:"" help:"Manage where provider passwords and tokens are stored."`
+	Provider ProviderCmd `cmd:"" help:"Add, list, remove, rename or activate providers without the setup forms."`
+	Doctor   DoctorCmd   `cmd:"" help:"Check the settings and credentials of the providers."`
+}
+
+// Globals holds the options shared by all commands.
+type Globals struct {
+	ConfigPath string
+}
+
+// UploadCmd uploads files, directories or stdin and prints the share links.
+type UploadCmd struct {
+	Setup   bool   `help:"Launch interactive setup." short:"s"`
+	Select  bool   `help:"Select provider for this upload." short:"p"`
+	Jobs    int    `help:"Number of files uploaded in parallel." short:"j" default:"3"`
+	List    string `help:"Print all links combined: lines or markdown." enum:",lines,markdown" default:"" placeholder:"FORMAT"`
+	Output  string `help:"Output format: text, json (one object per file) or plain (links only). json and plain skip the progress UI, QR code and clipboard." enum:"text,json,plain" default:"text" short:"o"`
+	NoTUI   bool   `name:"no-tui" help:"Never show the progress UI or forms: print progress to stderr and fail instead of asking (default if stdout is not a terminal)."`
+	Name    string `help:"Filename for content read from stdin." default:"stdin.txt"`
+	Archive string `help:"Archive format for directories (default: from preferences, zip)." enum:",zip,tar.gz" default:"" placeholder:"FORMAT"`
+	Expire  string `help:"Let the links expire after a duration, e.g. 7d, 2w or 12h." placeholder:"DURATION"`
+
+	Encrypt bool `help:"Encrypt files before upload; the key is added to the link after '#'." short:"e"`
+	Short   bool `help:"Shorten the links with the URL shortener from the preferences (default: biturl)."`
+
+	Password       string `help:"Protect the links with this password." xor:"password"`
+	RandomPassword bool   `help:"Protect the links with a generated password." xor:"password"`
+
+	Type string   `help:"Upload with an ad-hoc provider of this type, configured by --set and SHARECMD_* variables instead of the config file (default: $SHARECMD_PROVIDER_TYPE)." placeholder:"TYPE"`
+	Set  []string `help:"Override a provider setting for this upload; repeatable." placeholder:"KEY=VALUE" sep:"none"`
+
+	Args []string `arg:"" optional:"" help:"Files or directories to upload and optional provider name."`
 }
 
 func main() {
 	cli := CLI{}
-	kong.Parse(&cli,
+	ctx := kong.Parse(&cli,
 		kong.Name("share"),
 		kong.Description("Upload files to cloud storage and get a shareable link."),
 		kong.UsageOnError(),
 		kong.Vars{
 			"defaultConfigPath": config.DefaultConfigPath(),
+			"providerTypes":     strings.Join(setup.ProviderTypes, ", "),
 		},
 	)
 
@@ -57,15 +104,42 @@ func main() {
 	if configPath == "" {
 		configPath = config.DefaultConfigPath()
 	}
+	// Let setup check new providers; it can't create them itself.
+	setup.Check = func(cfg *config.Config, entry *config.ProviderEntry) error {
+		return checkProvider(context.Background(), cfg, entry)
+	}
+
+	err := ctx.Run(&Globals{ConfigPath: configPath})
+	ctx.FatalIfErrorf(err)
+}
+
+// Run uploads the given files with the active (or selected) provider.
+func (cli *UploadCmd) Run(g *Globals) error {
+	configPath := g.ConfigPath
+	outputFormat = cli.Output
+	// Without a terminal forms would hang, so fail instead of asking.
+	interactive := !cli.NoTUI && stdoutIsTerminal()
 
 	cfg, err := config.LookupConfig(configPath)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v\n", err)
+		fatalf(codeConfig, "Failed to load config: %v\n", err)
+	}
+	// A provider from --type or the environment needs no config file.
+	adhoc, err := config.AdHocProvider(cli.Type, os.Environ(), setup.SettingKeys)
+	if err != nil {
+		fatalf(codeUsage, "%v\n", err)
 	}
+	needSetup := adhoc == nil && cfg.ActiveProvider() == nil
 
-	if cli.Setup || cfg.ActiveProvider() == nil {
+	if !interactive && cli.Setup {
+		fatalf(codeUsage, "--setup needs a terminal\n")
+	}
+	if !interactive && needSetup {
+		fatalf(codeNoProvider, "No active provider configured. Run 'share --setup' in a terminal to configure.\n")
+	}
+	if cli.Setup || needSetup {
 		if err := setup.Run(cfg); err != nil {
-			log.Fatalf("Setup failed: %v\n", err)
+			fatalf(codeSetup, "Setup failed: %v\n", err)
 		}
 		if len(cli.Args) == 0 {
 			os.Exit(0)
@@ -73,71 +147,55 @@ func main() {
 		// Reload config after setup
 		cfg, err = config.LookupConfig(configPath)
 		if err != nil {
-			log.Fatalf("Failed to reload config: %v\n", err)
+			fatalf(codeConfig, "Failed to reload config: %v\n", err)
 		}
 	}
 
-	// Parse args: extract filename and optional provider override
-	if len(cli.Args) == 0 {
+	// Parse args: extract filenames and optional provider override.
+	// Without file arguments, piped stdin is uploaded.
+	if len(cli.Args) == 0 && !stdinIsPiped() {
 		os.Exit(0)
 	}
 
-	if len(cli.Args) > 2 {
-		log.Fatalf("Too many arguments. Usage: share [provider] <file> or share <file> [provider]\n")
+	expire, err := provider.ParseExpire(cli.Expire)
+	if err != nil {
+		fatalf(codeUsage, "Invalid --expire: %v\n", err)
 	}
-
-	var filename string
-	var providerLabel string
-
-	for _, arg := range cli.Args {
-		// First check if it's an existing file (to handle files named like providers)
-		_, statErr := os.Stat(arg)
-		if statErr == nil {
-			if filename != "" {
-				log.Fatalf("Multiple files specified: %q and %q\n", filename, arg)
-			}
-			filename = arg
-		} else if cfg.FindByLabel(arg) != nil {
-			// It's a provider label
-			if providerLabel != "" {
-				log.Fatalf("Multiple providers specified: %q and %q\n", providerLabel, arg)
-			}
-			providerLabel = arg
-		} else {
-			// Neither accessible file nor provider
-			// Check if it looks like a file path (has extension or path separator)
-			looksLikeFile := strings.Contains(arg, ".") || strings.Contains(arg, string(os.PathSeparator))
-			if looksLikeFile {
-				// Provide specific error for file access issues
-				if os.IsNotExist(statErr) {
-					log.Fatalf("File not found: %s\n", arg)
-				}
-				if os.IsPermission(statErr) {
-					log.Fatalf("Permission denied: %s (if using snap, try moving the file to your home directory)\n", arg)
-				}
-				// Generic file access error (e.g., snap confinement)
-				log.Fatalf("Cannot access file: %s (if using snap, try moving the file to your home directory)\n", arg)
-			}
-			// Doesn't look like a file, treat as unknown provider
-			log.Fatalf("Unknown provider: %q. Run 'share --setup' to configure providers.\n", arg)
+	linkOpts := provider.LinkOptions{Expire: expire, Password: cli.Password}
+	if cli.RandomPassword {
+		linkOpts.Password, err = provider.GeneratePassword(randomPasswordLength)
+		if err != nil {
+			fatalf(codeInternal, "Failed to generate password: %v\n", err)
 		}
 	}
 
-	if filename == "" {
-		log.Fatalf("No file to upload specified\n")
+	filenames, providerLabel := parseArgs(cfg, cli.Args)
+	if len(filenames) == 0 {
+		if !stdinIsPiped() {
+			fatalf(codeUsage, "No file to upload specified\n")
+		}
+		filenames = []string{stdinArg}
 	}
 
 	// Determine which provider to use
 	var active *config.ProviderEntry
-	if cli.Select || providerLabel != "" {
+	if adhoc != nil {
+		if cli.Select || providerLabel != "" {
+			fatalf(codeUsage, "A provider type from --type or %s can't be combined with a provider label or --select\n", config.EnvProviderType)
+		}
+		active = adhoc
+	} else if cli.Select || providerLabel != "" {
 		// Interactive provider selection
 		if providerLabel == "" {
 			if len(cfg.Providers) == 0 {
-				log.Fatal("No providers configured. Run 'share --setup' to configure.")
+				fatalf(codeNoProvider, "No providers configured. Run 'share --setup' to configure.")
+			}
+			if !interactive {
+				fatalf(codeUsage, "--select needs a terminal; pass the provider label as argument instead\n")
 			}
 			selected, err := setup.PickProvider(cfg, "Select provider for this upload")
 			if err != nil {
-				log.Fatalf("Provider selection cancelled: %v\n", err)
+				fatalf(codeCancelled, "Provider selection cancelled: %v\n", err)
 			}
 			providerLabel = selected
 		}
@@ -145,121 +203,244 @@ func main() {
 	} else {
 		active = cfg.ActiveProvider()
 		if active == nil {
-			log.Fatal("No active provider configured. Run 'share --setup' to configure.")
+			fatalf(codeNoProvider, "No active provider configured. Run 'share --setup' to configure.")
 		}
 	}
 
-	prov, err := instantiateProvider(active)
-	if err != nil {
-		log.Fatalf("Failed to create provider: %v\n", err)
+	if err := active.Override(cli.Set, setup.SettingKeys[active.Type]); err != nil {
+		fatalf(codeUsage, "Invalid --set: %v\n", err)
 	}
-
-	// Setup token refresh callback for OAuth2 providers
-	setupTokenRefresh(prov, active, cfg)
-
-	file, err := os.Open(filename)
-	if err != nil {
-		log.Fatalf("Can't open file: %v\n", err)
+	var prov provider.Provider
+	if adhoc != nil {
+		// Refreshed tokens are not saved, there is no config entry.
+		prov, err = instantiateProvider(active)
+	} else {
+		prov, err = openProvider(cfg, active)
 	}
-	defer file.Close()
-
-	fileInfo, err := file.Stat()
 	if err != nil {
-		log.Fatalf("Can't stat file: %v\n", err)
+		fatalf(codeProvider, "Failed to create provider: %v\n", err)
 	}
-	basename := filepath.Base(file.Name())
-	filesize := fileInfo.Size()
-
-	// Upload with progress TUI
-	var fileID string
-	var uploadErr error
-
-	model := upload.NewModel(basename, filesize)
-	p := tea.NewProgram(model)
-	pr := upload.NewProgressReader(file, filesize, p)
 
-	go func() {
-		fileID, uploadErr = prov.Upload(pr, basename, filesize)
-		upload.SendDone(p, fileID, uploadErr)
-	}()
+	// Cancelled by Ctrl+C in the progress UI or by SIGINT outside of it.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	if _, err := p.Run(); err != nil {
-		log.Fatalf("TUI error: %v\n", err)
+	// Fail before uploading anything if the links can't be created as requested.
+	if err := provider.CheckLinkOptions(ctx, prov, linkOpts); err != nil {
+		if errors.Is(err, provider.ErrUnsupported) {
+			fatalf(codeUnsupportedLink, "Provider %q (%s) cannot create links with %s\n", active.Label, active.Type, linkOpts)
+		}
+		fatalf(codeProvider, "Provider %q (%s): %v\n", active.Label, active.Type, err)
 	}
 
-	if uploadErr != nil {
-		if isOAuthTokenError(uploadErr) {
-			fmt.Printf("\nOAuth token has expired for provider %q.\n", active.Label)
-			if err := setup.ReconfigureProvider(cfg, active.Label); err != nil {
-				log.Fatalf("Re-authentication failed: %v\n", err)
-			}
-			// Reload config and retry
-			cfg, err = config.LookupConfig(configPath)
+	archiveFormat := cli.Archive
+	if archiveFormat == "" {
+		archiveFormat = cfg.ArchiveFormatOrDefault()
+	}
+	sources := make([]*source, len(filenames))
+	seen := make(map[string]string)
+	for i, filename := range filenames {
+		var src *source
+		if filename == stdinArg {
+			src = stdinSource(cli.Name)
+		} else {
+			src, err = newSource(filename, archiveFormat)
 			if err != nil {
-				log.Fatalf("Failed to reload config: %v\n", err)
+				fatalf(codeFileAccess, "%v\n", err)
 			}
-			active = cfg.FindByLabel(active.Label)
-			prov, err = instantiateProvider(active)
+		}
+		if other, ok := seen[src.name]; ok {
+			fatalf(codeUsage, "%q and %q would both be uploaded as %q\n", other, filename, src.name)
+		}
+		seen[src.name] = filename
+		if cli.Encrypt {
+			src, err = encryptedSource(src)
 			if err != nil {
-				log.Fatalf("Failed to create provider: %v\n", err)
-			}
-			setupTokenRefresh(prov, active, cfg)
-
-			// Retry upload
-			file.Seek(0, 0)
-			pr2 := upload.NewProgressReader(file, filesize, p)
-			fileID, uploadErr = prov.Upload(pr2, basename, filesize)
-			if uploadErr != nil {
-				log.Fatalf("Upload failed after re-authentication: %v\n", uploadErr)
+				fatalf(codeInternal, "%v\n", err)
 			}
-		} else {
-			log.Fatalf("Upload failed: %v\n", uploadErr)
 		}
+		sources[i] = src
 	}
 
-	link, err := prov.GetLink(fileID)
+	var results []*result
+	var cancelled bool
+	switch {
+	case !interactive:
+		results, cancelled = uploadQuiet(ctx, prov, sources, cli.Jobs, os.Stderr)
+	case cli.Output == outputText:
+		results, cancelled, err = uploadAll(ctx, prov, sources, cli.Jobs)
+	default:
+		results, cancelled = uploadQuiet(ctx, prov, sources, cli.Jobs, nil)
+	}
 	if err != nil {
-		if isOAuthTokenError(err) {
-			fmt.Printf("\nOAuth token has expired for provider %q.\n", active.Label)
-			if err := setup.ReconfigureProvider(cfg, active.Label); err != nil {
-				log.Fatalf("Re-authentication failed: %v\n", err)
-			}
-			// Reload config and retry
-			cfg, err = config.LookupConfig(configPath)
-			if err != nil {
-				log.Fatalf("Failed to reload config: %v\n", err)
-			}
-			active = cfg.FindByLabel(active.Label)
-			prov, err = instantiateProvider(active)
-			if err != nil {
-				log.Fatalf("Failed to create provider: %v\n", err)
+		fatalf(codeInternal, "TUI error: %v\n", err)
+	}
+	if cancelled {
+		if outputFormat == outputJSON {
+			printError(codeCancelled, "upload cancelled")
+		}
+		os.Exit(130)
+	}
+
+	// reauth authenticates anew if any of failed hit an expired OAuth
+	// token and then runs retry for each of them. If that is not possible,
+	// the results keep an error that says why.
+	reauth := func(failed []*result, retry func(*result)) {
+		if !anyOAuthTokenError(failed) {
+			return
+		}
+		var err error
+		if adhoc != nil {
+			err = fmt.Errorf("OAuth token of the %s provider has expired; pass a new token", active.Type)
+		} else {
+			cfg, active, prov, err = reauthenticate(cfg, configPath, active.Label, interactive, cli.Set)
+		}
+		for _, res := range failed {
+			switch {
+			case err == nil:
+				retry(res)
+			case isOAuthTokenError(res.err):
+				res.fail(codeAuth, err)
 			}
-			setupTokenRefresh(prov, active, cfg)
+		}
+	}
 
-			// Retry GetLink
-			link, err = prov.GetLink(fileID)
-			if err != nil {
-				log.Fatalf("GetLink failed after re-authentication: %v\n", err)
+	reauth(failedResults(results), func(res *result) {
+		res.fileID, res.err = uploadOne(ctx, prov, res, nil, 0)
+	})
+	for _, res := range failedResults(results) {
+		if res.code == "" {
+			res.fail(codeUpload, fmt.Errorf("Upload of %s failed: %w", res.src.name, res.err))
+		}
+	}
+
+	// Files that failed to upload keep their error; the others get links.
+	uploaded := succeededResults(results)
+	for _, res := range uploaded {
+		res.err = createLink(ctx, prov, res, linkOpts)
+	}
+	reauth(failedResults(uploaded), func(res *result) {
+		res.err = createLink(ctx, prov, res, linkOpts)
+	})
+	for _, res := range failedResults(uploaded) {
+		if res.code == "" {
+			res.fail(codeLink, fmt.Errorf("Can't get link for %s: %w", res.src.name, res.err))
+		}
+	}
+
+	// Links of the files that made it are shown and recorded even if
+	// others failed, so that they can still be shared or removed.
+	shared := succeededResults(results)
+	if cli.Short || cfg.ShortenLinksEnabled() {
+		shortenLinks(ctx, cfg, shared)
+	}
+
+	if cfg.HistoryEnabled() {
+		recordHistory(configPath, active, adhoc != nil, shared)
+	}
+
+	failed := failedResults(results)
+	if cli.Output == outputJSON {
+		printJSON(active, results)
+	} else {
+		if len(shared) > 0 {
+			if cli.Output == outputPlain {
+				printPlain(shared)
+			} else {
+				printResults(cfg, shared, cli.List, interactive)
 			}
-		} else {
-			log.Fatalf("Can't get link: %v\n", err)
+		}
+		for _, res := range failed {
+			printError(res.code, res.err.Error())
 		}
 	}
+	if len(failed) > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
 
-	if cfg.ShowQRCodeEnabled() {
-		fmt.Println()
-		if cfg.IsSixelEnabled() && qrterminal.IsSixelSupported(os.Stdout) {
-			qrterminal.Generate(link, qrterminal.L, os.Stdout)
+// parseArgs splits the positional arguments into files to upload and an
+// optional provider label. Existing files win over provider labels so that
+// files named like a provider can still be shared.
+func parseArgs(cfg *config.Config, args []string) (filenames []string, providerLabel string) {
+	for _, arg := range args {
+		// First check if it's an existing file (to handle files named like providers)
+		_, statErr := os.Stat(arg)
+		if arg == stdinArg {
+			if slices.Contains(filenames, stdinArg) {
+				fatalf(codeUsage, "stdin (%q) can only be specified once\n", stdinArg)
+			}
+			filenames = append(filenames, arg)
+		} else if statErr == nil {
+			filenames = append(filenames, arg)
+		} else if cfg.FindByLabel(arg) != nil {
+			// It's a provider label
+			if providerLabel != "" {
+				fatalf(codeUsage, "Multiple providers specified: %q and %q\n", providerLabel, arg)
+			}
+			providerLabel = arg
 		} else {
-			qrterminal.GenerateHalfBlock(link, qrterminal.L, os.Stdout)
+			// Neither accessible file nor provider
+			// Check if it looks like a file path (has extension or path separator)
+			looksLikeFile := strings.Contains(arg, ".") || strings.Contains(arg, string(os.PathSeparator))
+			if looksLikeFile {
+				// Provide specific error for file access issues
+				if os.IsNotExist(statErr) {
+					fatalf(codeFileNotFound, "File not found: %s\n", arg)
+				}
+				if os.IsPermission(statErr) {
+					fatalf(codePermission, "Permission denied: %s (if using snap, try moving the file to your home directory)\n", arg)
+				}
+				// Generic file access error (e.g., snap confinement)
+				fatalf(codeFileAccess, "Cannot access file: %s (if using snap, try moving the file to your home directory)\n", arg)
+			}
+			// Doesn't look like a file, treat as unknown provider
+			fatalf(codeUnknownProvider, "Unknown provider: %q. Run 'share --setup' to configure providers.\n", arg)
 		}
-		fmt.Println()
 	}
-	fmt.Printf("URL: %s\n", link)
+	return filenames, providerLabel
+}
+
+// reauthenticate runs the provider form again after an OAuth token expired
+// and returns the reloaded config, entry (with the --set overrides sets) and
+// provider. Without interactive it fails, since the form needs a terminal.
+func reauthenticate(cfg *config.Config, configPath, label string, interactive bool, sets []string) (*config.Config, *config.ProviderEntry, provider.Provider, error) {
+	if !interactive {
+		return nil, nil, nil, fmt.Errorf("OAuth token has expired for provider %q. Run 'share --setup' in a terminal to re-authenticate", label)
+	}
+	fmt.Fprintf(os.Stderr, "\nOAuth token has expired for provider %q.\n", label)
+	if err := setup.ReconfigureProvider(cfg, label); err != nil {
+		return nil, nil, nil, fmt.Errorf("re-authentication failed: %w", err)
+	}
+	// Reload config and retry
+	cfg, err := config.LookupConfig(configPath)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to reload config: %w", err)
+	}
+	active := cfg.FindByLabel(label)
+	if err := active.Override(sets, setup.SettingKeys[active.Type]); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid --set: %w", err)
+	}
+	prov, err := openProvider(cfg, active)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create provider: %w", err)
+	}
+	return cfg, active, prov, nil
+}
 
-	if cfg.CopyToClipboardEnabled() {
-		clipboard.ToClip(link)
+// openProvider reads the secrets of entry from the secret store, creates the
+// provider and saves refreshed OAuth tokens to cfg.
+func openProvider(cfg *config.Config, entry *config.ProviderEntry) (provider.Provider, error) {
+	if err := cfg.ResolveSecrets(entry); err != nil {
+		return nil, err
 	}
+	prov, err := instantiateProvider(entry)
+	if err != nil {
+		return nil, err
+	}
+	setupTokenRefresh(prov, entry, cfg)
+	return prov, nil
 }
 
 func instantiateProvider(entry *config.ProviderEntry) (provider.Provider, error) {
@@ -281,14 +462,169 @@ func instantiateProvider(entry *config.ProviderEntry) (provider.Provider, error)
 			RandomPasswordChars:   cast.ToInt(entry.Settings["randomPasswordChars"]),
 		}), nil
 	case "box":
-		return box.NewProvider(entry.Settings["token"]), nil
+		prov, err := box.NewProvider(entry.Settings["token"])
+		if err != nil {
+			return nil, err
+		}
+		return prov, nil
 	case "googledrive":
-		return googledrive.NewProvider(entry.Settings["googletoken"]), nil
+		prov, err := googledrive.NewProvider(entry.Settings["googletoken"])
+		if err != nil {
+			return nil, err
+		}
+		return prov, nil
+	case "s3":
+		linkExpiry, err := provider.ParseExpire(entry.Settings["linkExpiry"])
+		if err != nil {
+			return nil, fmt.Errorf("s3 link expiry: %w", err)
+		}
+		prov, err := s3.NewProvider(s3.Config{
+			Endpoint:        entry.Settings["endpoint"],
+			Region:          entry.Settings["region"],
+			Bucket:          entry.Settings["bucket"],
+			Prefix:          entry.Settings["prefix"],
+			AccessKeyID:     entry.Settings["accessKeyID"],
+			SecretAccessKey: entry.Settings["secretAccessKey"],
+			PathStyle:       cast.ToBool(entry.Settings["pathStyle"]),
+			LinkExpiry:      linkExpiry,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return prov, nil
+	case "azureblob":
+		linkExpiry, err := provider.ParseExpire(entry.Settings["linkExpiry"])
+		if err != nil {
+			return nil, fmt.Errorf("azureblob link expiry: %w", err)
+		}
+		prov, err := azureblob.NewProvider(azureblob.Config{
+			ConnectionString: entry.Settings["connectionString"],
+			AccountName:      entry.Settings["accountName"],
+			AccountKey:       entry.Settings["accountKey"],
+			Endpoint:         entry.Settings["endpoint"],
+			Container:        entry.Settings["container"],
+			Prefix:           entry.Settings["prefix"],
+			LinkExpiry:       linkExpiry,
+			Concurrency:      cast.ToInt(entry.Settings["concurrency"]),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return prov, nil
+	case "sftp":
+		return sftp.NewProvider(sftp.Config{
+			Host:       entry.Settings["host"],
+			User:       entry.Settings["user"],
+			Auth:       entry.Settings["auth"],
+			KeyFile:    expandHome(entry.Settings["keyFile"]),
+			Passphrase: entry.Settings["passphrase"],
+			Password:   entry.Settings["password"],
+			KnownHosts: expandHome(entry.Settings["knownHosts"]),
+			RemoteDir:  entry.Settings["remoteDir"],
+			BaseURL:    entry.Settings["baseURL"],
+		}), nil
+	case "ftp":
+		prov, err := ftp.NewProvider(ftp.Config{
+			Host:         entry.Settings["host"],
+			User:         entry.Settings["user"],
+			Password:     entry.Settings["password"],
+			TLS:          entry.Settings["tls"],
+			SkipVerify:   cast.ToBool(entry.Settings["skipVerify"]),
+			DisableEPSV:  cast.ToBool(entry.Settings["disableEPSV"]),
+			RemoteDir:    entry.Settings["remoteDir"],
+			LinkTemplate: entry.Settings["linkTemplate"],
+		})
+		if err != nil {
+			return nil, err
+		}
+		return prov, nil
+	case "webdav":
+		prov, err := webdav.NewProvider(webdav.Config{
+			URL:          entry.Settings["url"],
+			Dir:          entry.Settings["dir"],
+			Auth:         entry.Settings["auth"],
+			Username:     entry.Settings["username"],
+			Password:     entry.Settings["password"],
+			Token:        entry.Settings["token"],
+			LinkTemplate: entry.Settings["linkTemplate"],
+		})
+		if err != nil {
+			return nil, err
+		}
+		return prov, nil
+	case "paste":
+		config, err := pasteConfig(entry.Settings)
+		if err != nil {
+			return nil, err
+		}
+		prov, err := paste.NewProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return prov, nil
+	case "gist":
+		prov, err := gist.NewProvider(gist.Config{
+			Token:       entry.Settings["token"],
+			APIURL:      entry.Settings["apiURL"],
+			Public:      cast.ToBool(entry.Settings["public"]),
+			Description: entry.Settings["description"],
+			Link:        entry.Settings["link"],
+			Separate:    cast.ToBool(entry.Settings["separate"]),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return prov, nil
 	default:
 		return nil, fmt.Errorf("unknown provider type: %s", entry.Type)
 	}
 }
 
+// pasteConfig builds the paste config from a preset or the custom settings.
+func pasteConfig(settings map[string]string) (paste.Config, error) {
+	var config paste.Config
+	if preset := settings["preset"]; preset != paste.PresetCustom {
+		var err error
+		if config, err = paste.PresetConfig(preset, settings["server"]); err != nil {
+			return config, err
+		}
+	} else {
+		config = paste.Config{
+			Server:       settings["server"],
+			URL:          settings["url"],
+			Method:       settings["method"],
+			Body:         settings["body"],
+			Field:        settings["field"],
+			LinkField:    settings["linkField"],
+			LinkTemplate: settings["linkTemplate"],
+		}
+		if s := settings["fields"]; s != "" {
+			if err := json.Unmarshal([]byte(s), &config.Fields); err != nil {
+				return config, fmt.Errorf("paste fields: %w", err)
+			}
+		}
+	}
+	if s := settings["headers"]; s != "" {
+		if err := json.Unmarshal([]byte(s), &config.Headers); err != nil {
+			return config, fmt.Errorf("paste headers: %w", err)
+		}
+	}
+	return config, nil
+}
+
+// expandHome replaces a leading "~/" with the home directory.
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
+
 // OAuth2Provider is an interface for providers that support OAuth2 token refresh
 type OAuth2Provider interface {
 	SetTokenRefreshCallback(func(*oauth2.Token))
@@ -301,7 +637,12 @@ func setupTokenRefresh(prov provider.Provider, entry *config.ProviderEntry, cfg
 		return // Not an OAuth2 provider
 	}
 
+	var mu sync.Mutex
 	oauth2Prov.SetTokenRefreshCallback(func(newToken *oauth2.Token) {
+		// Parallel uploads may refresh at the same time.
+		mu.Lock()
+		defer mu.Unlock()
+
 		tokenJSON, err := json.Marshal(newToken)
 		if err != nil {
 			log.Printf("Warning: failed to marshal refreshed token: %v\n", err)
@@ -315,13 +656,30 @@ func setupTokenRefresh(prov provider.Provider, entry *config.ProviderEntry, cfg
 		}
 		entry.Settings[settingKey] = string(tokenJSON)
 
-		// Save config to disk
-		if err := cfg.Write(); err != nil {
+		// Save only the token, keeping what other share processes saved
+		// meanwhile, e.g. their own refreshed tokens.
+		err = cfg.Update(func(fresh *config.Config) error {
+			if e := fresh.FindByLabel(entry.Label); e != nil {
+				e.Settings[settingKey] = string(tokenJSON)
+			}
+			return nil
+		})
+		if err != nil {
 			log.Printf("Warning: failed to save refreshed token to config: %v\n", err)
 		}
 	})
 }
 
+// anyOAuthTokenError reports whether any result failed with an expired token.
+func anyOAuthTokenError(results []*result) bool {
+	for _, res := range results {
+		if isOAuthTokenError(res.err) {
+			return true
+		}
+	}
+	return false
+}
+
 // isOAuthTokenError checks if an error is related to expired/invalid OAuth tokens
 func isOAuthTokenError(err error) bool {
 	if err == nil {
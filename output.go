@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mdp/qrterminal/v3"
+	"schneider.vip/share/clipboard"
+	"schneider.vip/share/config"
+)
+
+// Formats of --output.
+const (
+	outputText  = "text"
+	outputJSON  = "json"
+	outputPlain = "plain"
+)
+
+// outputFormat is the --output of the upload command; it decides how fatalf
+// reports errors.
+var outputFormat = outputText
+
+// Error codes of --output json. Scripts depend on them, so they must not be
+// changed.
+const (
+	codeConfig          = "config_error"
+	codeSetup           = "setup_failed"
+	codeUsage           = "invalid_usage"
+	codeFileNotFound    = "file_not_found"
+	codePermission      = "permission_denied"
+	codeFileAccess      = "file_access"
+	codeUnknownProvider = "unknown_provider"
+	codeNoProvider      = "no_provider"
+	codeProvider        = "provider_error"
+	codeUnsupportedLink = "unsupported_link_options"
+	codeUpload          = "upload_failed"
+	codeLink            = "link_failed"
+	codeAuth            = "auth_failed"
+	codeCancelled       = "cancelled"
+	codeInternal        = "internal_error"
+)
+
+// jsonResult is the --output json line for one uploaded file. Field names
+// match the upload history.
+type jsonResult struct {
+	Name     string    `json:"name"`
+	Provider string    `json:"provider"`
+	Type     string    `json:"type"`
+	FileID   string    `json:"file_id"`
+	Link     string    `json:"link"`
+	LongLink string    `json:"long_link,omitempty"`
+	Size     int64     `json:"size"`
+	SHA256   string    `json:"sha256"`
+	Password string    `json:"password,omitempty"`
+	Expires  time.Time `json:"expires,omitzero"`
+	// DurationMS is the upload time in milliseconds.
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// jsonErrorInfo is the error of a --output json line.
+type jsonErrorInfo struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// jsonError is the --output json line for an error.
+type jsonError struct {
+	Error jsonErrorInfo `json:"error"`
+}
+
+// jsonFailure is the --output json line for a file that failed to upload
+// or to get a link.
+type jsonFailure struct {
+	Name     string        `json:"name"`
+	Provider string        `json:"provider"`
+	Type     string        `json:"type"`
+	Error    jsonErrorInfo `json:"error"`
+}
+
+// printJSON prints one JSON object per line for each file, in order: the
+// link of an uploaded file or the error of a failed one.
+func printJSON(entry *config.ProviderEntry, results []*result) {
+	enc := json.NewEncoder(os.Stdout)
+	for _, res := range results {
+		if res.err != nil {
+			enc.Encode(jsonFailure{
+				Name:     res.src.name,
+				Provider: entry.Label,
+				Type:     entry.Type,
+				Error:    jsonErrorInfo{Code: res.code, Message: res.err.Error()},
+			})
+			continue
+		}
+		enc.Encode(jsonResult{
+			Name:       res.src.name,
+			Provider:   entry.Label,
+			Type:       entry.Type,
+			FileID:     res.fileID,
+			Link:       res.link,
+			LongLink:   res.longLink,
+			Size:       res.size,
+			SHA256:     res.checksum,
+			Password:   res.password,
+			Expires:    res.expires,
+			DurationMS: res.duration.Milliseconds(),
+		})
+	}
+}
+
+// printPlain prints only the links, one per line. A password follows its
+// link after a tab.
+func printPlain(results []*result) {
+	for _, res := range results {
+		if res.password != "" {
+			fmt.Printf("%s\t%s\n", res.link, res.password)
+		} else {
+			fmt.Println(res.link)
+		}
+	}
+}
+
+// printError reports an error with its code: as JSON object on stdout for
+// --output json, otherwise on stderr.
+func printError(code, msg string) {
+	if outputFormat != outputJSON {
+		log.Print(msg)
+		return
+	}
+	var e jsonError
+	e.Error.Code = code
+	e.Error.Message = msg
+	json.NewEncoder(os.Stdout).Encode(e)
+}
+
+// fatalf reports an error with printError and exits with status 1.
+func fatalf(code, format string, args ...any) {
+	printError(code, strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+	os.Exit(1)
+}
+
+// printResults prints the links of all uploaded files. A single link is shown
+// with an optional QR code; several links are listed one per file, or
+// combined as plain lines or a Markdown list if list is set. The QR code and
+// clipboard are skipped unless interactive.
+func printResults(cfg *config.Config, results []*result, list string, interactive bool) {
+	if len(results) == 1 && list == "" {
+		link := results[0].link
+		if interactive && cfg.ShowQRCodeEnabled() {
+			fmt.Println()
+			if cfg.IsSixelEnabled() && qrterminal.IsSixelSupported(os.Stdout) {
+				qrterminal.Generate(link, qrterminal.L, os.Stdout)
+			} else {
+				qrterminal.GenerateHalfBlock(link, qrterminal.L, os.Stdout)
+			}
+			fmt.Println()
+		}
+		fmt.Printf("URL: %s\n", link)
+		if results[0].password != "" {
+			fmt.Printf("Password: %s\n", results[0].password)
+		}
+		if !results[0].expires.IsZero() {
+			fmt.Printf("Expires: %s\n", results[0].expires.Local().Format(time.DateTime))
+		}
+
+		if interactive && cfg.CopyToClipboardEnabled() {
+			clipboard.ToClip(link)
+		}
+		return
+	}
+
+	combined := combineLinks(results, list)
+	if list == "" {
+		fmt.Println()
+		for _, res := range results {
+			fmt.Printf("%s: %s%s\n", res.src.name, res.link, passwordSuffix(res))
+		}
+		if exp := results[0].expires; !exp.IsZero() {
+			fmt.Printf("Links expire: %s\n", exp.Local().Format(time.DateTime))
+		}
+	} else {
+		fmt.Print(combined)
+	}
+
+	if interactive && cfg.CopyToClipboardEnabled() {
+		clipboard.ToClip(strings.TrimSuffix(combined, "\n"))
+	}
+}
+
+// combineLinks formats all links as one string: a Markdown list for
+// "markdown", otherwise one URL per line. Passwords are appended to their
+// link.
+func combineLinks(results []*result, list string) string {
+	var b strings.Builder
+	for _, res := range results {
+		if list == "markdown" {
+			fmt.Fprintf(&b, "- [%s](%s)%s\n", res.src.name, res.link, passwordSuffix(res))
+		} else {
+			fmt.Fprintf(&b, "%s%s\n", res.link, passwordSuffix(res))
+		}
+	}
+	return b.String()
+}
+
+// passwordSuffix returns " (password: ...)" for protected links. Passwords
+// can differ per file, e.g. when Nextcloud generates them.
+func passwordSuffix(res *result) string {
+	if res.password == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (password: %s)", res.password)
+}
@@ -0,0 +1,404 @@
+package azureblob
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"schneider.vip/share/provider"
+)
+
+const (
+	// DefaultLinkExpiry is the lifetime of SAS links if none is set.
+	DefaultLinkExpiry = 7 * 24 * time.Hour
+	// DefaultConcurrency is the number of blocks uploaded in parallel.
+	DefaultConcurrency = 4
+
+	defaultBlockSize = 8 * 1024 * 1024
+	maxBlocks        = 50000
+	// sasClockSkew backdates the SAS start time, since the server clock
+	// may be a little behind.
+	sasClockSkew = 5 * time.Minute
+)
+
+// Config describes a container in an Azure storage account.
+type Config struct {
+	// ConnectionString, if set, provides the account name, key and
+	// endpoint, e.g. as copied from the Azure portal or for Azurite.
+	ConnectionString string
+	AccountName      string
+	// AccountKey is the base64 encoded shared key.
+	AccountKey string
+	// Endpoint overrides the blob endpoint, e.g.
+	// http://127.0.0.1:10000/devstoreaccount1 for Azurite.
+	Endpoint    string
+	Container   string
+	Prefix      string
+	LinkExpiry  time.Duration
+	Concurrency int
+}
+
+// Provider uploads block blobs and shares read-only SAS URLs.
+type Provider struct {
+	config    Config
+	key       sharedKey
+	endpoint  *url.URL
+	client    *http.Client
+	blockSize int64
+	now       func() time.Time
+
+	// containerMu serializes creating the container so parallel uploads
+	// only do it once.
+	containerMu    sync.Mutex
+	containerReady bool
+}
+
+// parseConnectionString fills account, key and endpoint from a connection
+// string like "DefaultEndpointsProtocol=https;AccountName=...;AccountKey=...;EndpointSuffix=core.windows.net".
+func parseConnectionString(s string, config *Config) error {
+	values := map[string]string{}
+	for _, part := range strings.Split(s, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			values[strings.ToLower(k)] = v
+		}
+	}
+	if values["accountname"] == "" || values["accountkey"] == "" {
+		return errors.New("connection string needs AccountName and AccountKey")
+	}
+	config.AccountName = values["accountname"]
+	config.AccountKey = values["accountkey"]
+	if ep := values["blobendpoint"]; ep != "" {
+		config.Endpoint = ep
+	} else if suffix := values["endpointsuffix"]; suffix != "" {
+		protocol := values["defaultendpointsprotocol"]
+		if protocol == "" {
+			protocol = "https"
+		}
+		config.Endpoint = fmt.Sprintf("%s://%s.blob.%s", protocol, config.AccountName, suffix)
+	}
+	return nil
+}
+
+// NewProvider creates a new Azure Blob Storage provider.
+func NewProvider(config Config) (*Provider, error) {
+	if config.ConnectionString != "" {
+		if err := parseConnectionString(config.ConnectionString, &config); err != nil {
+			return nil, fmt.Errorf("azureblob: %w", err)
+		}
+	}
+	if config.AccountName == "" || config.Container == "" {
+		return nil, errors.New("azureblob: account name and container are required")
+	}
+	key, err := base64.StdEncoding.DecodeString(config.AccountKey)
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("azureblob: account key must be base64 encoded")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", config.AccountName)
+	}
+	endpoint, err := url.Parse(strings.TrimRight(config.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("azureblob: invalid endpoint %q", config.Endpoint)
+	}
+	if config.LinkExpiry <= 0 {
+		config.LinkExpiry = DefaultLinkExpiry
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = DefaultConcurrency
+	}
+	return &Provider{
+		config:    config,
+		key:       sharedKey{account: config.AccountName, key: key},
+		endpoint:  endpoint,
+		client:    http.DefaultClient,
+		blockSize: defaultBlockSize,
+		now:       time.Now,
+	}, nil
+}
+
+// blobName returns the name an upload named filename is stored under.
+func (p *Provider) blobName(filename string) string {
+	prefix := strings.Trim(p.config.Prefix, "/")
+	if prefix == "" {
+		return filename
+	}
+	return prefix + "/" + filename
+}
+
+// blobURL returns the URL of the named blob (or of the container if name is
+// empty) with the given query.
+func (p *Provider) blobURL(name string, query url.Values) *url.URL {
+	u := *p.endpoint
+	u.RawPath = u.EscapedPath() + "/" + url.PathEscape(p.config.Container)
+	if name != "" {
+		escaped := strings.Split(name, "/")
+		for i, s := range escaped {
+			escaped[i] = url.PathEscape(s)
+		}
+		u.RawPath += "/" + strings.Join(escaped, "/")
+	}
+	u.Path, _ = url.PathUnescape(u.RawPath)
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// do signs and sends a request. Replies with an error status other than the
+// accepted ones are returned as error.
+func (p *Provider) do(ctx context.Context, method string, u *url.URL, body []byte, header http.Header, accept ...int) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if len(body) == 0 {
+		req.Body = http.NoBody
+	}
+	for name, v := range header {
+		req.Header[name] = v
+	}
+	p.key.sign(req, p.now())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		for _, code := range accept {
+			if resp.StatusCode == code {
+				return resp, nil
+			}
+		}
+		defer resp.Body.Close()
+		return nil, responseError(method, resp)
+	}
+	return resp, nil
+}
+
+// responseError turns an Azure error reply into an error.
+func responseError(method string, resp *http.Response) error {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(b, &e) == nil && e.Code != "" {
+		msg, _, _ := strings.Cut(e.Message, "\n")
+		return fmt.Errorf("azureblob: %s %s failed (%d): %s: %s", method, resp.Request.URL.Path, resp.StatusCode, e.Code, msg)
+	}
+	return fmt.Errorf("azureblob: %s %s failed (%d): %s", method, resp.Request.URL.Path, resp.StatusCode, resp.Header.Get("x-ms-error-code"))
+}
+
+// ensureContainer creates the container on first use. 409 means it exists.
+func (p *Provider) ensureContainer(ctx context.Context) error {
+	p.containerMu.Lock()
+	defer p.containerMu.Unlock()
+	if p.containerReady {
+		return nil
+	}
+	resp, err := p.do(ctx, "PUT", p.blobURL("", url.Values{"restype": {"container"}}), nil, nil, http.StatusConflict)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	p.containerReady = true
+	return nil
+}
+
+// Check implements provider.Checker by reading the container properties,
+// which verifies the account key. A missing container is fine, since it is
+// created on the first upload.
+func (p *Provider) Check(ctx context.Context) error {
+	resp, err := p.do(ctx, "GET", p.blobURL("", url.Values{"restype": {"container"}}), nil, nil, http.StatusNotFound)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Upload stores the content as block blob. Content that fits into one block
+// is sent with a single Put Blob, everything else as blocks uploaded in
+// parallel and committed with Put Block List. Uncommitted blocks are
+// discarded by Azure after a week, so nothing is left behind on failure.
+func (p *Provider) Upload(ctx context.Context, r io.Reader, filename string, size int64) (string, error) {
+	if err := p.ensureContainer(ctx); err != nil {
+		return "", err
+	}
+	name := p.blobName(filename)
+	header := http.Header{}
+	if ct := mime.TypeByExtension(path.Ext(filename)); ct != "" {
+		header.Set("x-ms-blob-content-type", ct)
+	}
+
+	blockSize := p.blockSize
+	if size > 0 && size/maxBlocks >= blockSize {
+		blockSize = size/maxBlocks + 1
+	}
+	first := make([]byte, blockSize)
+	n, err := io.ReadFull(r, first)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		header.Set("x-ms-blob-type", "BlockBlob")
+		resp, err := p.do(ctx, "PUT", p.blobURL(name, nil), first[:n], header)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+		return name, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	ids, err := p.putBlocks(ctx, name, r, first)
+	if err != nil {
+		return "", err
+	}
+	if err := p.putBlockList(ctx, name, ids, header); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// blockID returns the ID of block i. All IDs of a blob must have the same
+// length.
+func blockID(i int) string {
+	return base64.StdEncoding.EncodeToString(fmt.Appendf(nil, "block-%06d", i))
+}
+
+// putBlocks uploads first and the rest of r as blocks with up to
+// Concurrency requests in flight and returns the block IDs in order.
+func (p *Provider) putBlocks(ctx context.Context, name string, r io.Reader, first []byte) ([]string, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		ids []string
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, p.config.Concurrency)
+	)
+	block := first
+	for i := 0; ; i++ {
+		if i >= maxBlocks {
+			cancel(fmt.Errorf("azureblob: content exceeds %d blocks", maxBlocks))
+			break
+		}
+		id := blockID(i)
+		ids = append(ids, id)
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(data []byte) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			query := url.Values{"comp": {"block"}, "blockid": {id}}
+			resp, err := p.do(ctx, "PUT", p.blobURL(name, query), data, nil)
+			if err != nil {
+				cancel(err)
+				return
+			}
+			resp.Body.Close()
+		}(block)
+
+		// Each block needs its own buffer while it is in flight.
+		next := make([]byte, len(first))
+		n, err := io.ReadFull(r, next)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			cancel(err)
+			break
+		}
+		block = next[:n]
+	}
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// putBlockList commits the blocks in order.
+func (p *Provider) putBlockList(ctx context.Context, name string, ids []string, header http.Header) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"BlockList"`
+		Latest  []string `xml:"Latest"`
+	}{Latest: ids})
+	if err != nil {
+		return err
+	}
+	body = append([]byte(xml.Header), body...)
+	resp, err := p.do(ctx, "PUT", p.blobURL(name, url.Values{"comp": {"blocklist"}}), body, header)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// GetLink returns a read-only SAS URL for the blob, valid for LinkExpiry.
+func (p *Provider) GetLink(ctx context.Context, name string) (string, error) {
+	link, err := p.CreateLink(ctx, name, provider.LinkOptions{})
+	if err != nil {
+		return "", err
+	}
+	return link.URL, nil
+}
+
+// CheckLinkOptions implements provider.LinkCreator. SAS URLs can expire at
+// any time but cannot have a password.
+func (p *Provider) CheckLinkOptions(ctx context.Context, opts provider.LinkOptions) error {
+	if opts.Password != "" {
+		return fmt.Errorf("password: %w", provider.ErrUnsupported)
+	}
+	return nil
+}
+
+// CreateLink returns a read-only SAS URL. opts.Expire overrides LinkExpiry.
+func (p *Provider) CreateLink(ctx context.Context, name string, opts provider.LinkOptions) (*provider.Link, error) {
+	if err := p.CheckLinkOptions(ctx, opts); err != nil {
+		return nil, err
+	}
+	expiry := p.config.LinkExpiry
+	if opts.Expire > 0 {
+		expiry = opts.Expire
+	}
+	now := p.now().Truncate(time.Second)
+	expires := now.Add(expiry)
+
+	protocol := "https"
+	if p.endpoint.Scheme == "http" {
+		protocol = "https,http"
+	}
+	sas := p.key.blobSAS(p.config.Container, name, now.Add(-sasClockSkew), expires, protocol)
+	return &provider.Link{
+		URL:     p.blobURL(name, sas).String(),
+		Expires: expires,
+	}, nil
+}
+
+// Delete removes the blob. SAS links to it stop working.
+func (p *Provider) Delete(ctx context.Context, name string) error {
+	resp, err := p.do(ctx, "DELETE", p.blobURL(name, nil), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
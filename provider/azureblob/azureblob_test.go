@@ -0,0 +1,349 @@
+package azureblob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"schneider.vip/share/provider"
+)
+
+// The well-known Azurite development account.
+const (
+	devAccount = "devstoreaccount1"
+	devKey     = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+func TestStringToSign(t *testing.T) {
+	s := sharedKey{account: "myaccount"}
+	req, _ := http.NewRequest("PUT", "https://myaccount.blob.core.windows.net/mycontainer/a%20b.txt?comp=block&blockid=YQ%3D%3D", strings.NewReader("hello"))
+	req.Header.Set("x-ms-date", "Fri, 26 Jun 2015 23:39:12 GMT")
+	req.Header.Set("x-ms-version", apiVersion)
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+
+	want := "PUT\n\n\n5\n\n\n\n\n\n\n\n\n" +
+		"x-ms-blob-type:BlockBlob\n" +
+		"x-ms-date:Fri, 26 Jun 2015 23:39:12 GMT\n" +
+		"x-ms-version:" + apiVersion + "\n" +
+		"/myaccount/mycontainer/a%20b.txt\nblockid:YQ==\ncomp:block"
+	if got := s.stringToSign(req); got != want {
+		t.Errorf("stringToSign =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestParseConnectionString(t *testing.T) {
+	tests := []struct {
+		conn     string
+		endpoint string
+	}{
+		{
+			conn:     "DefaultEndpointsProtocol=https;AccountName=acc;AccountKey=" + devKey + ";EndpointSuffix=core.windows.net",
+			endpoint: "https://acc.blob.core.windows.net",
+		},
+		{
+			conn:     "DefaultEndpointsProtocol=http;AccountName=acc;AccountKey=" + devKey + ";BlobEndpoint=http://127.0.0.1:10000/acc;",
+			endpoint: "http://127.0.0.1:10000/acc",
+		},
+	}
+	for _, tt := range tests {
+		var c Config
+		if err := parseConnectionString(tt.conn, &c); err != nil {
+			t.Fatal(err)
+		}
+		if c.AccountName != "acc" || c.AccountKey != devKey || c.Endpoint != tt.endpoint {
+			t.Errorf("parseConnectionString(%q) = %+v", tt.conn, c)
+		}
+	}
+	if err := parseConnectionString("AccountName=acc", &Config{}); err == nil {
+		t.Error("missing AccountKey accepted")
+	}
+}
+
+// fakeBlob is an in-memory Blob service with Azurite-style path addressing
+// (/account/container/blob). It verifies Shared Key and SAS signatures with
+// its own copy of the algorithm.
+type fakeBlob struct {
+	key []byte
+
+	mu        sync.Mutex
+	container bool
+	blobs     map[string][]byte
+	blocks    map[string][]byte
+	puts      int
+}
+
+func newFakeBlob(t *testing.T) (*fakeBlob, *httptest.Server) {
+	key, _ := base64.StdEncoding.DecodeString(devKey)
+	f := &fakeBlob{key: key, blobs: map[string][]byte{}, blocks: map[string][]byte{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeBlob) mac(s string) string {
+	h := hmac.New(sha256.New, f.key)
+	h.Write([]byte(s))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func (f *fakeBlob) checkSharedKey(r *http.Request) bool {
+	var headers []string
+	for _, name := range []string{"x-ms-blob-content-type", "x-ms-blob-type", "x-ms-date", "x-ms-version"} {
+		if v := r.Header.Get(name); v != "" {
+			headers = append(headers, name+":"+v)
+		}
+	}
+	resource := "/" + devAccount + r.URL.EscapedPath()
+	q := r.URL.Query()
+	for _, k := range []string{"blockid", "comp", "restype"} {
+		if v := q.Get(k); v != "" {
+			resource += "\n" + k + ":" + v
+		}
+	}
+	length := r.Header.Get("Content-Length")
+	if length == "0" {
+		length = ""
+	}
+	s := r.Method + "\n\n\n" + length + "\n\n" + r.Header.Get("Content-Type") + "\n\n\n\n\n\n\n" +
+		strings.Join(headers, "\n") + "\n" + resource
+	return r.Header.Get("Authorization") == "SharedKey "+devAccount+":"+f.mac(s)
+}
+
+func (f *fakeBlob) checkSAS(r *http.Request, name string) bool {
+	q := r.URL.Query()
+	se, err := time.Parse(time.RFC3339, q.Get("se"))
+	if err != nil || time.Now().After(se) || q.Get("sp") != "r" || q.Get("sr") != "b" {
+		return false
+	}
+	s := strings.Join([]string{"r", q.Get("st"), q.Get("se"), "/blob/" + devAccount + "/c/" + name,
+		"", "", q.Get("spr"), q.Get("sv"), "b", "", "", "", "", "", "", ""}, "\n")
+	return q.Get("sig") == f.mac(s)
+}
+
+func (f *fakeBlob) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	account, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	container, name, _ := strings.Cut(rest, "/")
+	if account != devAccount || container != "c" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method == "GET" && r.URL.Query().Has("sig") {
+		if !f.checkSAS(r, name) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	} else if !f.checkSharedKey(r) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?><Error><Code>AuthenticationFailed</Code><Message>Server failed to authenticate the request.
+RequestId:1</Message></Error>`)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	q := r.URL.Query()
+	switch {
+	case r.Method == "PUT" && q.Get("restype") == "container":
+		if f.container {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.container = true
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "GET" && q.Get("restype") == "container":
+		if !f.container {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case !f.container:
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == "PUT" && q.Get("comp") == "block":
+		f.blocks[name+"/"+q.Get("blockid")] = body
+		f.puts++
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "PUT" && q.Get("comp") == "blocklist":
+		var list struct {
+			Latest []string `xml:"Latest"`
+		}
+		if err := xml.Unmarshal(body, &list); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var data []byte
+		for _, id := range list.Latest {
+			block, ok := f.blocks[name+"/"+id]
+			if !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data = append(data, block...)
+		}
+		f.blobs[name] = data
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "PUT":
+		if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.blobs[name] = body
+		f.puts++
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "GET":
+		data, ok := f.blobs[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case r.Method == "DELETE":
+		if _, ok := f.blobs[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestProvider(t *testing.T, srv *httptest.Server) *Provider {
+	t.Helper()
+	p, err := NewProvider(Config{
+		ConnectionString: "DefaultEndpointsProtocol=http;AccountName=" + devAccount + ";AccountKey=" + devKey + ";BlobEndpoint=" + srv.URL + "/" + devAccount,
+		Container:        "c",
+		Prefix:           "/share/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.blockSize = 1024
+	return p
+}
+
+func download(t *testing.T, link string) []byte {
+	t.Helper()
+	resp, err := http.Get(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %s", link, resp.Status)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	return b
+}
+
+func TestUploadAndLink(t *testing.T) {
+	f, srv := newFakeBlob(t)
+	p := newTestProvider(t, srv)
+	ctx := context.Background()
+
+	for _, size := range []int{0, 10, 1024, 10*1024 + 7} {
+		content := make([]byte, size)
+		rand.Read(content)
+		// Hide the size like a pipe does.
+		id, err := p.Upload(ctx, io.MultiReader(bytes.NewReader(content)), "a b.bin", provider.UnknownSize)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if id != "share/a b.bin" {
+			t.Errorf("id = %q", id)
+		}
+		link, err := p.GetLink(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(link, "/share/a%20b.bin?") {
+			t.Errorf("link = %s", link)
+		}
+		if got := download(t, link); !bytes.Equal(got, content) {
+			t.Errorf("size %d: downloaded %d bytes", size, len(got))
+		}
+	}
+	if f.puts != 1+1+1+11 {
+		t.Errorf("puts = %d", f.puts)
+	}
+
+	if err := p.Delete(ctx, "share/a b.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.blobs) != 0 {
+		t.Errorf("blobs left: %d", len(f.blobs))
+	}
+}
+
+func TestCreateLink(t *testing.T) {
+	_, srv := newFakeBlob(t)
+	p := newTestProvider(t, srv)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+
+	link, err := p.CreateLink(context.Background(), "x.txt", provider.LinkOptions{Expire: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !link.Expires.Equal(now.Add(time.Hour)) {
+		t.Errorf("expires = %v", link.Expires)
+	}
+	u, _ := url.Parse(link.URL)
+	q := u.Query()
+	if q.Get("se") != "2024-05-01T13:00:00Z" || q.Get("sp") != "r" || q.Get("spr") != "https,http" {
+		t.Errorf("SAS = %s", u.RawQuery)
+	}
+
+	_, err = p.CreateLink(context.Background(), "x.txt", provider.LinkOptions{Password: "pw"})
+	if !errors.Is(err, provider.ErrUnsupported) {
+		t.Errorf("password: err = %v", err)
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	_, srv := newFakeBlob(t)
+	p, err := NewProvider(Config{
+		AccountName: devAccount,
+		AccountKey:  base64.StdEncoding.EncodeToString([]byte("wrong")),
+		Endpoint:    srv.URL + "/" + devAccount,
+		Container:   "c",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.Upload(context.Background(), strings.NewReader("x"), "a.txt", 1)
+	if err == nil || !strings.Contains(err.Error(), "AuthenticationFailed: Server failed to authenticate the request.") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	_, srv := newFakeBlob(t)
+	p := newTestProvider(t, srv)
+	if err := p.Check(context.Background()); err != nil {
+		t.Fatalf("Check before the container exists: %v", err)
+	}
+	if _, err := p.Upload(context.Background(), strings.NewReader("x"), "a.txt", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Check(context.Background()); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	p.key.key = []byte("wrong")
+	if err := p.Check(context.Background()); err == nil || !strings.Contains(err.Error(), "AuthenticationFailed") {
+		t.Errorf("err = %v, want AuthenticationFailed", err)
+	}
+}
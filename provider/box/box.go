@@ -10,11 +10,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"mime/multipart"
 	"net/http"
+	"sync"
+	"time"
 
 	"golang.org/x/oauth2"
+	"schneider.vip/share/provider"
 )
 
 const (
@@ -37,6 +39,13 @@ type Provider struct {
 	token       *oauth2.Token
 	tokenSource oauth2.TokenSource
 	onTokenRefresh func(newToken *oauth2.Token)
+
+	// tokenMu guards token against concurrent refreshes.
+	tokenMu sync.Mutex
+
+	// folderID caches the ID of the sharecmd folder; folderMu guards it.
+	folderMu sync.Mutex
+	folderID string
 }
 
 // OAuth2BoxConfig returns the OAuth2 config for Box
@@ -54,10 +63,10 @@ func OAuth2BoxConfig() *oauth2.Config {
 }
 
 // NewProvider creates a new Box Provider from a JSON-encoded oauth2.Token
-func NewProvider(token string) *Provider {
+func NewProvider(token string) (*Provider, error) {
 	tok := &oauth2.Token{}
 	if err := json.Unmarshal([]byte(token), tok); err != nil {
-		log.Fatalf("Unable to parse Box token: %v", err)
+		return nil, fmt.Errorf("unable to parse Box token: %w", err)
 	}
 	cfg := OAuth2BoxConfig()
 	p := &Provider{
@@ -65,15 +74,18 @@ func NewProvider(token string) *Provider {
 		config: cfg,
 	}
 	p.tokenSource = &notifyingTokenSource{
-		src: cfg.TokenSource(context.Background(), tok),
+		src:  cfg.TokenSource(context.Background(), tok),
+		last: tok.AccessToken,
 		onRefresh: func(newToken *oauth2.Token) {
+			p.tokenMu.Lock()
 			p.token = newToken
+			p.tokenMu.Unlock()
 			if p.onTokenRefresh != nil {
 				p.onTokenRefresh(newToken)
 			}
 		},
 	}
-	return p
+	return p, nil
 }
 
 // SetTokenRefreshCallback sets a callback that's invoked when the token is refreshed
@@ -83,33 +95,49 @@ func (p *Provider) SetTokenRefreshCallback(callback func(newToken *oauth2.Token)
 
 // GetCurrentToken returns the current (possibly refreshed) token
 func (p *Provider) GetCurrentToken() *oauth2.Token {
+	p.tokenMu.Lock()
+	defer p.tokenMu.Unlock()
 	return p.token
 }
 
-func (p *Provider) httpClient() *http.Client {
-	return oauth2.NewClient(context.Background(), p.tokenSource)
+func (p *Provider) httpClient(ctx context.Context) *http.Client {
+	return oauth2.NewClient(ctx, p.tokenSource)
 }
 
 // notifyingTokenSource wraps a TokenSource and calls a callback on token refresh
 type notifyingTokenSource struct {
 	src       oauth2.TokenSource
 	onRefresh func(*oauth2.Token)
+
+	// mu serializes Token for parallel uploads; last is the access token
+	// seen before, so onRefresh only runs when it changes.
+	mu   sync.Mutex
+	last string
 }
 
 func (n *notifyingTokenSource) Token() (*oauth2.Token, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
 	token, err := n.src.Token()
 	if err != nil {
 		return nil, err
 	}
+	if token.AccessToken == n.last {
+		return token, nil
+	}
+	n.last = token.AccessToken
 	if n.onRefresh != nil {
 		n.onRefresh(token)
 	}
 	return token, nil
 }
 
-// Upload uploads a file to Box inside a "sharecmd" folder and returns the file ID
-func (p *Provider) Upload(r io.Reader, filename string, size int64) (string, error) {
-	client := p.httpClient()
+// Upload uploads a file to Box inside a "sharecmd" folder and returns the file ID.
+// Box only creates the file once the upload request has completed, so a
+// cancelled upload leaves nothing behind. The content is buffered, so size
+// may be provider.UnknownSize.
+func (p *Provider) Upload(ctx context.Context, r io.Reader, filename string, size int64) (string, error) {
+	client := p.httpClient(ctx)
 
 	// Buffer content so it can be reused if we need to upload a new version
 	content, err := io.ReadAll(r)
@@ -117,7 +145,7 @@ func (p *Provider) Upload(r io.Reader, filename string, size int64) (string, err
 		return "", fmt.Errorf("read file: %w", err)
 	}
 
-	folderID, err := getOrCreateFolder(client, "sharecmd")
+	folderID, err := p.sharecmdFolder(ctx, client)
 	if err != nil {
 		return "", fmt.Errorf("folder: %w", err)
 	}
@@ -138,7 +166,7 @@ func (p *Provider) Upload(r io.Reader, filename string, size int64) (string, err
 	}
 	mw.Close()
 
-	req, err := http.NewRequest("POST", uploadBase+"/files/content", &body)
+	req, err := http.NewRequestWithContext(ctx, "POST", uploadBase+"/files/content", &body)
 	if err != nil {
 		return "", err
 	}
@@ -163,7 +191,7 @@ func (p *Provider) Upload(r io.Reader, filename string, size int64) (string, err
 		if err := json.Unmarshal(b, &conflict); err != nil || conflict.ContextInfo.Conflicts.ID == "" {
 			return "", fmt.Errorf("upload conflict but could not parse existing file ID: %s", string(b))
 		}
-		return p.uploadNewVersion(client, conflict.ContextInfo.Conflicts.ID, filename, content)
+		return p.uploadNewVersion(ctx, client, conflict.ContextInfo.Conflicts.ID, filename, content)
 	}
 
 	if resp.StatusCode != http.StatusCreated {
@@ -186,7 +214,7 @@ func (p *Provider) Upload(r io.Reader, filename string, size int64) (string, err
 }
 
 // uploadNewVersion replaces an existing file on Box with new content
-func (p *Provider) uploadNewVersion(client *http.Client, fileID, filename string, content []byte) (string, error) {
+func (p *Provider) uploadNewVersion(ctx context.Context, client *http.Client, fileID, filename string, content []byte) (string, error) {
 	var body bytes.Buffer
 	mw := multipart.NewWriter(&body)
 
@@ -203,7 +231,7 @@ func (p *Provider) uploadNewVersion(client *http.Client, fileID, filename string
 	}
 	mw.Close()
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/files/%s/content", uploadBase, fileID), &body)
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/files/%s/content", uploadBase, fileID), &body)
 	if err != nil {
 		return "", err
 	}
@@ -235,28 +263,71 @@ func (p *Provider) uploadNewVersion(client *http.Client, fileID, filename string
 }
 
 // GetLink creates a public shared link for the given file ID and returns the URL
-func (p *Provider) GetLink(fileID string) (string, error) {
-	client := p.httpClient()
+func (p *Provider) GetLink(ctx context.Context, fileID string) (string, error) {
+	link, err := p.CreateLink(ctx, fileID, provider.LinkOptions{})
+	if err != nil {
+		return "", err
+	}
+	return link.URL, nil
+}
 
-	payload := `{"shared_link":{"access":"open"}}`
-	req, err := http.NewRequest("PUT",
+// freeMaxUploadSize is the upload limit of free personal Box accounts, which
+// tell them apart from paid ones: the user object has no account type.
+const freeMaxUploadSize = 250 << 20
+
+// CheckLinkOptions implements provider.LinkCreator. Shared links support an
+// unshared_at expiry and a password, but free accounts can't set the expiry,
+// so the account is looked up when an expiry is requested.
+func (p *Provider) CheckLinkOptions(ctx context.Context, opts provider.LinkOptions) error {
+	if opts.Expire <= 0 {
+		return nil
+	}
+	user, err := p.currentUser(ctx, "max_upload_size,enterprise")
+	if err != nil {
+		return err
+	}
+	if user.Enterprise == nil && user.MaxUploadSize <= freeMaxUploadSize {
+		return fmt.Errorf("expiry on a free Box account: %w", provider.ErrUnsupported)
+	}
+	return nil
+}
+
+// CreateLink creates an open shared link with the given options.
+func (p *Provider) CreateLink(ctx context.Context, fileID string, opts provider.LinkOptions) (*provider.Link, error) {
+	client := p.httpClient(ctx)
+
+	type sharedLink struct {
+		Access     string `json:"access"`
+		UnsharedAt string `json:"unshared_at,omitempty"`
+		Password   string `json:"password,omitempty"`
+	}
+	sl := sharedLink{Access: "open", Password: opts.Password}
+	expires := opts.ExpiresAt(time.Now().Truncate(time.Second))
+	if !expires.IsZero() {
+		sl.UnsharedAt = expires.Format(time.RFC3339)
+	}
+	payload, err := json.Marshal(map[string]sharedLink{"shared_link": sl})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "PUT",
 		fmt.Sprintf("%s/files/%s?fields=shared_link", apiBase, fileID),
-		bytes.NewBufferString(payload),
+		bytes.NewReader(payload),
 	)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		b, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("shared link failed (%d): %s", resp.StatusCode, string(b))
+		return nil, fmt.Errorf("shared link failed (%d): %s", resp.StatusCode, string(b))
 	}
 
 	var result struct {
@@ -265,14 +336,110 @@ func (p *Provider) GetLink(fileID string) (string, error) {
 		} `json:"shared_link"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &provider.Link{URL: result.SharedLink.URL, Expires: expires, Password: opts.Password}, nil
+}
+
+// Delete moves the file to the Box trash.
+func (p *Provider) Delete(ctx context.Context, fileID string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", fmt.Sprintf("%s/files/%s", apiBase, fileID), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.httpClient(ctx).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		b, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("delete failed (%d): %s", resp.StatusCode, string(b))
+	}
+	return nil
+}
+
+// Revoke removes the shared link of the file.
+func (p *Provider) Revoke(ctx context.Context, fileID string) error {
+	req, err := http.NewRequestWithContext(ctx, "PUT",
+		fmt.Sprintf("%s/files/%s?fields=shared_link", apiBase, fileID),
+		bytes.NewBufferString(`{"shared_link":null}`),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient(ctx).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("revoke shared link failed (%d): %s", resp.StatusCode, string(b))
+	}
+	return nil
+}
+
+// Check implements provider.Checker by fetching the current user, which
+// refreshes an expired token.
+func (p *Provider) Check(ctx context.Context) error {
+	_, err := p.currentUser(ctx, "id")
+	return err
+}
+
+// user holds the fields of the Box user object sharecmd looks at.
+type user struct {
+	ID            string `json:"id"`
+	MaxUploadSize int64  `json:"max_upload_size"`
+	Enterprise    *struct {
+		ID string `json:"id"`
+	} `json:"enterprise"`
+}
+
+// currentUser fetches the given fields of the user the token belongs to.
+func (p *Provider) currentUser(ctx context.Context, fields string) (*user, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", apiBase+"/users/me?fields="+fields, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient(ctx).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("user lookup failed (%d): %s", resp.StatusCode, string(b))
+	}
+	var u user
+	if err := json.NewDecoder(resp.Body).Decode(&u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (p *Provider) sharecmdFolder(ctx context.Context, client *http.Client) (string, error) {
+	p.folderMu.Lock()
+	defer p.folderMu.Unlock()
+	if p.folderID != "" {
+		return p.folderID, nil
+	}
+	id, err := getOrCreateFolder(ctx, client, "sharecmd")
+	if err != nil {
 		return "", err
 	}
-	return result.SharedLink.URL, nil
+	p.folderID = id
+	return id, nil
 }
 
-func getOrCreateFolder(client *http.Client, name string) (string, error) {
+func getOrCreateFolder(ctx context.Context, client *http.Client, name string) (string, error) {
 	// Search in root folder (id "0")
-	req, err := http.NewRequest("GET",
+	req, err := http.NewRequestWithContext(ctx, "GET",
 		fmt.Sprintf("%s/folders/0/items?fields=id,name,type&limit=1000", apiBase),
 		nil,
 	)
@@ -303,7 +470,7 @@ func getOrCreateFolder(client *http.Client, name string) (string, error) {
 
 	// Create folder
 	payload := fmt.Sprintf(`{"name":%q,"parent":{"id":"0"}}`, name)
-	req, err = http.NewRequest("POST", apiBase+"/folders", bytes.NewBufferString(payload))
+	req, err = http.NewRequestWithContext(ctx, "POST", apiBase+"/folders", bytes.NewBufferString(payload))
 	if err != nil {
 		return "", err
 	}
@@ -1,6 +1,7 @@
 package dropbox
 
 import (
+	"bytes"
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
@@ -8,15 +9,21 @@ import (
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/sharing"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/users"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/users_common"
 	"golang.org/x/oauth2"
+	"schneider.vip/share/provider"
 )
 
 const chunkSize int64 = 1 << 24
@@ -83,6 +90,9 @@ type Provider struct {
 	tokenSource    oauth2.TokenSource
 	onTokenRefresh func(newToken *oauth2.Token)
 	isLegacyToken  bool
+
+	// tokenMu guards token; parallel uploads may refresh it.
+	tokenMu sync.Mutex
 }
 
 // NewProvider creates a new Provider.
@@ -101,9 +111,12 @@ func NewProvider(tokenJSON string) *Provider {
 			oauthConfig: oauthCfg,
 		}
 		p.tokenSource = &notifyingTokenSource{
-			src: oauthCfg.TokenSource(context.Background(), &tok),
+			src:  oauthCfg.TokenSource(context.Background(), &tok),
+			last: tok.AccessToken,
 			onRefresh: func(newToken *oauth2.Token) {
+				p.tokenMu.Lock()
 				p.token = newToken
+				p.tokenMu.Unlock()
 				if p.onTokenRefresh != nil {
 					p.onTokenRefresh(newToken)
 				}
@@ -128,6 +141,8 @@ func (c *Provider) SetTokenRefreshCallback(callback func(newToken *oauth2.Token)
 
 // GetCurrentToken returns the current (possibly refreshed) token
 func (c *Provider) GetCurrentToken() *oauth2.Token {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
 	return c.token
 }
 
@@ -135,25 +150,63 @@ func (c *Provider) GetCurrentToken() *oauth2.Token {
 type notifyingTokenSource struct {
 	src       oauth2.TokenSource
 	onRefresh func(*oauth2.Token)
+
+	// mu serializes Token for parallel uploads; last is the access token
+	// seen before, so onRefresh only runs when it changes.
+	mu   sync.Mutex
+	last string
 }
 
 func (n *notifyingTokenSource) Token() (*oauth2.Token, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
 	token, err := n.src.Token()
 	if err != nil {
 		return nil, err
 	}
+	if token.AccessToken == n.last {
+		return token, nil
+	}
+	n.last = token.AccessToken
 	if n.onRefresh != nil {
 		n.onRefresh(token)
 	}
 	return token, nil
 }
 
-// Upload the file to dropbox
-func (c *Provider) Upload(r io.Reader, filename string, size int64) (dst string, err error) {
+// contextTransport attaches a context to every request the SDK sends, since
+// the Dropbox SDK has no context-aware methods of its own.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
+// configFor returns a copy of the SDK config whose HTTP client is bound to ctx.
+func (c *Provider) configFor(ctx context.Context) dropbox.Config {
+	cfg := c.Config
+	client := cfg.Client
+	if client == nil {
+		client = oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: cfg.Token}))
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	cfg.Client = &http.Client{Transport: &contextTransport{ctx: ctx, base: base}}
+	return cfg
+}
+
+// Upload the file to dropbox. Chunked uploads are only committed by the final
+// UploadSessionFinish call, so a cancelled upload leaves no partial file.
+func (c *Provider) Upload(ctx context.Context, r io.Reader, filename string, size int64) (dst string, err error) {
 	dst = "/" + filename
 
 	delarg := files.NewDeleteArg(dst)
-	dbx := files.New(c.Config)
+	dbx := files.New(c.configFor(ctx))
 	dbx.DeleteV2(delarg)
 
 	uploadArg := files.NewUploadArg(dst)
@@ -162,6 +215,9 @@ func (c *Provider) Upload(r io.Reader, filename string, size int64) (dst string,
 	// The Dropbox API only accepts timestamps in UTC with second precision.
 	t := time.Now().UTC().Round(time.Second)
 	uploadArg.ClientModified = &t
+	if size == provider.UnknownSize {
+		return dst, uploadStream(dbx, r, uploadArg)
+	}
 	if size > chunkSize {
 		return dst, uploadChunked(dbx, r, &uploadArg.CommitInfo, size)
 	}
@@ -173,21 +229,101 @@ func (c *Provider) Upload(r io.Reader, filename string, size int64) (dst string,
 }
 
 // GetLink for file
-func (c *Provider) GetLink(filepath string) (string, error) {
-	share := sharing.New(c.Config)
+func (c *Provider) GetLink(ctx context.Context, filepath string) (string, error) {
+	link, err := c.CreateLink(ctx, filepath, provider.LinkOptions{})
+	if err != nil {
+		return "", err
+	}
+	return link.URL, nil
+}
+
+// CheckLinkOptions implements provider.LinkCreator. Shared link settings
+// support an expiry and a password, but not on basic (free) accounts, so the
+// account type is looked up when options are set.
+func (c *Provider) CheckLinkOptions(ctx context.Context, opts provider.LinkOptions) error {
+	if opts.IsZero() {
+		return nil
+	}
+	account, err := users.New(c.configFor(ctx)).GetCurrentAccount()
+	if err != nil {
+		return err
+	}
+	if account.AccountType != nil && account.AccountType.Tag == users_common.AccountTypeBasic {
+		return fmt.Errorf("%s on a basic Dropbox account: %w", opts, provider.ErrUnsupported)
+	}
+	return nil
+}
+
+// CreateLink creates a shared link with the given settings.
+func (c *Provider) CreateLink(ctx context.Context, filepath string, opts provider.LinkOptions) (*provider.Link, error) {
+	share := sharing.New(c.configFor(ctx))
 	arg := sharing.NewCreateSharedLinkWithSettingsArg(filepath)
 
+	// The Dropbox API only accepts timestamps in UTC with second precision.
+	expires := opts.ExpiresAt(time.Now().UTC().Round(time.Second))
+	if !opts.IsZero() {
+		arg.Settings = sharing.NewSharedLinkSettings()
+	}
+	if !expires.IsZero() {
+		arg.Settings.Expires = &expires
+	}
+	if opts.Password != "" {
+		arg.Settings.RequirePassword = true
+		arg.Settings.LinkPassword = opts.Password
+	}
+
 	res, err := share.CreateSharedLinkWithSettings(arg)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	switch sl := res.(type) {
 	case *sharing.FileLinkMetadata:
-		return fixDropboxDownloadlink(sl.SharedLinkMetadata.Url), nil
+		return &provider.Link{URL: fixDropboxDownloadlink(sl.SharedLinkMetadata.Url), Expires: expires, Password: opts.Password}, nil
+	}
+
+	return &provider.Link{}, nil
+}
+
+// Delete removes the file from dropbox.
+func (c *Provider) Delete(ctx context.Context, filepath string) error {
+	_, err := files.New(c.configFor(ctx)).DeleteV2(files.NewDeleteArg(filepath))
+	return err
+}
+
+// Revoke removes all shared links of the file.
+func (c *Provider) Revoke(ctx context.Context, filepath string) error {
+	share := sharing.New(c.configFor(ctx))
+	arg := sharing.NewListSharedLinksArg()
+	arg.Path = filepath
+	arg.DirectOnly = true
+
+	res, err := share.ListSharedLinks(arg)
+	if err != nil {
+		return err
+	}
+	for _, l := range res.Links {
+		var url string
+		switch sl := l.(type) {
+		case *sharing.FileLinkMetadata:
+			url = sl.Url
+		case *sharing.FolderLinkMetadata:
+			url = sl.Url
+		default:
+			continue
+		}
+		if err := share.RevokeSharedLink(sharing.NewRevokeSharedLinkArg(url)); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	return "", nil
+// Check implements provider.Checker by fetching the current account, which
+// refreshes an expired token.
+func (c *Provider) Check(ctx context.Context) error {
+	_, err := users.New(c.configFor(ctx)).GetCurrentAccount()
+	return err
 }
 
 // fixDropboxDownloadlink replaces dl=0 with dl=1 on for dropbox links to
@@ -226,6 +362,47 @@ func uploadChunked(dbx files.Client, r io.Reader, commitInfo *files.CommitInfo,
 	return
 }
 
+// uploadStream uploads content of unknown length. Chunks are read into memory
+// until EOF; a stream that fits into one chunk is sent with a single Upload.
+func uploadStream(dbx files.Client, r io.Reader, uploadArg *files.UploadArg) error {
+	buf := make([]byte, chunkSize)
+	var sessionID string
+	var written uint64
+
+	for {
+		n, err := io.ReadFull(r, buf)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return err
+		}
+		chunk := bytes.NewReader(buf[:n])
+
+		if last {
+			if sessionID == "" {
+				_, err = dbx.Upload(uploadArg, chunk)
+				return err
+			}
+			cursor := files.NewUploadSessionCursor(sessionID, written)
+			_, err = dbx.UploadSessionFinish(files.NewUploadSessionFinishArg(cursor, &uploadArg.CommitInfo), chunk)
+			return err
+		}
+
+		if sessionID == "" {
+			res, err := dbx.UploadSessionStart(files.NewUploadSessionStartArg(), chunk)
+			if err != nil {
+				return err
+			}
+			sessionID = res.SessionId
+		} else {
+			cursor := files.NewUploadSessionCursor(sessionID, written)
+			if err := dbx.UploadSessionAppendV2(files.NewUploadSessionAppendArg(cursor), chunk); err != nil {
+				return err
+			}
+		}
+		written += uint64(n)
+	}
+}
+
 type obf struct {
 	jkoq []byte
 }
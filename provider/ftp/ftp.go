@@ -0,0 +1,269 @@
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/jlaffaye/ftp"
+	"schneider.vip/share/provider"
+)
+
+// TLS modes.
+const (
+	TLSNone     = "none"
+	TLSExplicit = "explicit"
+	TLSImplicit = "implicit"
+)
+
+// TLSModes lists all TLS modes.
+var TLSModes = []string{TLSExplicit, TLSImplicit, TLSNone}
+
+// Config describes the FTP server, the directory uploads go to and how they
+// are linked.
+type Config struct {
+	// Host is host or host:port; the port defaults to 21, or 990 with
+	// implicit TLS.
+	Host string
+	// User and Password log in; an empty User logs in anonymously.
+	User     string
+	Password string
+	// TLS is one of TLSModes. Explicit TLS upgrades the connection with
+	// AUTH TLS (FTPES), implicit TLS connects with TLS right away (FTPS).
+	TLS string
+	// SkipVerify accepts any server certificate, e.g. a self-signed one.
+	SkipVerify bool
+	// DisableEPSV uses PASV instead of EPSV for passive data connections,
+	// for servers behind NAT that answer EPSV wrongly.
+	DisableEPSV bool
+	// RemoteDir is the upload directory, relative to the login directory
+	// unless absolute. Missing directories are created.
+	RemoteDir string
+	// LinkTemplate builds the public link, e.g.
+	// https://example.com/drop/{{.Name}}. Fields: .Name (path-escaped
+	// filename), .RawName and .Dir (path-escaped RemoteDir). If empty, an
+	// ftp:// URL without credentials is returned.
+	LinkTemplate string
+}
+
+// LinkData is passed to Config.LinkTemplate.
+type LinkData struct {
+	Name    string
+	RawName string
+	Dir     string
+}
+
+// Provider uploads files to an FTP server. Every operation uses its own
+// control connection, since commands on one connection cannot overlap.
+type Provider struct {
+	config    Config
+	link      *template.Template
+	tlsConfig *tls.Config
+
+	// dirReady is set once ensureDir succeeded; dirMu guards it, since the
+	// MKD probes of parallel uploads would fail against each other.
+	dirMu    sync.Mutex
+	dirReady bool
+}
+
+// NewProvider creates a new FTP provider. It connects on first use.
+func NewProvider(config Config) (*Provider, error) {
+	if config.Host == "" {
+		return nil, errors.New("ftp: host is required")
+	}
+	p := &Provider{config: config}
+	switch config.TLS {
+	case TLSNone, "":
+	case TLSExplicit, TLSImplicit:
+		host, _, err := net.SplitHostPort(config.Host)
+		if err != nil {
+			host = config.Host
+		}
+		p.tlsConfig = &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: config.SkipVerify,
+			// Many servers require the data connections to resume the
+			// session of the control connection.
+			ClientSessionCache: tls.NewLRUClientSessionCache(0),
+		}
+	default:
+		return nil, fmt.Errorf("ftp: unknown TLS mode: %s", config.TLS)
+	}
+	if config.LinkTemplate != "" {
+		link, err := template.New("link").Parse(config.LinkTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("ftp: invalid link template: %w", err)
+		}
+		p.link = link
+	}
+	return p, nil
+}
+
+// address returns Host with the default port added if needed.
+func (p *Provider) address() string {
+	if _, _, err := net.SplitHostPort(p.config.Host); err == nil {
+		return p.config.Host
+	}
+	port := "21"
+	if p.config.TLS == TLSImplicit {
+		port = "990"
+	}
+	return net.JoinHostPort(p.config.Host, port)
+}
+
+// connect dials the server and logs in.
+func (p *Provider) connect(ctx context.Context) (*ftp.ServerConn, error) {
+	opts := []ftp.DialOption{
+		ftp.DialWithContext(ctx),
+		ftp.DialWithDisabledEPSV(p.config.DisableEPSV),
+	}
+	switch p.config.TLS {
+	case TLSExplicit:
+		opts = append(opts, ftp.DialWithExplicitTLS(p.tlsConfig))
+	case TLSImplicit:
+		opts = append(opts, ftp.DialWithTLS(p.tlsConfig))
+	}
+	c, err := ftp.Dial(p.address(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("ftp: %w", err)
+	}
+
+	user, password := p.config.User, p.config.Password
+	if user == "" {
+		user, password = "anonymous", "anonymous"
+	}
+	if err := c.Login(user, password); err != nil {
+		c.Quit()
+		return nil, fmt.Errorf("ftp login: %w", err)
+	}
+	return c, nil
+}
+
+// Check implements provider.Checker by logging in.
+func (p *Provider) Check(ctx context.Context) error {
+	c, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// ensureDir creates RemoteDir one directory at a time, since MKD does not
+// create parents. A failing MKD is fine if the directory exists. Paths are
+// made absolute first, since probing a directory with CWD changes the
+// directory relative paths are resolved against.
+func (p *Provider) ensureDir(c *ftp.ServerConn) error {
+	p.dirMu.Lock()
+	defer p.dirMu.Unlock()
+	if p.dirReady {
+		return nil
+	}
+
+	dir := p.config.RemoteDir
+	if strings.Trim(dir, "/") == "" {
+		p.dirReady = true
+		return nil
+	}
+	cwd, err := c.CurrentDir()
+	if err != nil {
+		return err
+	}
+	current := cwd
+	if strings.HasPrefix(dir, "/") {
+		current = "/"
+	}
+	for _, seg := range strings.Split(dir, "/") {
+		if seg == "" {
+			continue
+		}
+		current = path.Join(current, seg)
+		if err := c.MakeDir(current); err != nil {
+			if c.ChangeDir(current) != nil {
+				c.ChangeDir(cwd)
+				return fmt.Errorf("can't create %s: %w", current, err)
+			}
+		}
+	}
+	if err := c.ChangeDir(cwd); err != nil {
+		return err
+	}
+	p.dirReady = true
+	return nil
+}
+
+// remotePath returns the path of filename on the server.
+func (p *Provider) remotePath(filename string) string {
+	if p.config.RemoteDir == "" {
+		return filename
+	}
+	return path.Join(p.config.RemoteDir, filename)
+}
+
+// Upload streams the content to RemoteDir/filename. A partial file is
+// removed if the upload fails or ctx is cancelled.
+func (p *Provider) Upload(ctx context.Context, r io.Reader, filename string, size int64) (string, error) {
+	c, err := p.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer c.Quit()
+	if err := p.ensureDir(c); err != nil {
+		return "", err
+	}
+
+	remote := p.remotePath(filename)
+	// STOR takes no context; failing reads abort it on cancel.
+	if err := c.Stor(remote, provider.ContextReader(ctx, r)); err != nil {
+		c.Delete(remote)
+		return "", fmt.Errorf("can't upload %s: %w", remote, err)
+	}
+	return filename, nil
+}
+
+// GetLink executes LinkTemplate for the file, or returns its ftp:// URL.
+func (p *Provider) GetLink(ctx context.Context, filename string) (string, error) {
+	var segs []string
+	for _, s := range strings.Split(p.config.RemoteDir, "/") {
+		if s != "" {
+			segs = append(segs, url.PathEscape(s))
+		}
+	}
+	data := LinkData{
+		Name:    url.PathEscape(filename),
+		RawName: filename,
+		Dir:     strings.Join(segs, "/"),
+	}
+	if p.link == nil {
+		u := "ftp://" + p.config.Host + "/"
+		if data.Dir != "" {
+			u += data.Dir + "/"
+		}
+		return u + data.Name, nil
+	}
+	var b strings.Builder
+	if err := p.link.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("link template: %w", err)
+	}
+	if b.Len() == 0 {
+		return "", errors.New("link template produced an empty link")
+	}
+	return b.String(), nil
+}
+
+// Delete removes the file from RemoteDir.
+func (p *Provider) Delete(ctx context.Context, filename string) error {
+	c, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Quit()
+	return c.Delete(p.remotePath(filename))
+}
@@ -0,0 +1,414 @@
+package ftp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testUser = "me"
+	testPass = "secret"
+)
+
+// fakeFTP is a minimal in-process FTP server with an in-memory file system.
+// It supports passive mode (EPSV and PASV) and explicit and implicit TLS.
+type fakeFTP struct {
+	t        *testing.T
+	tls      *tls.Config
+	implicit bool
+	noEPSV   bool
+
+	mu    sync.Mutex
+	dirs  map[string]bool
+	files map[string][]byte
+}
+
+func newFakeFTP(t *testing.T, mode string, noEPSV bool) (*fakeFTP, string, *x509.CertPool) {
+	// Borrow the certificate of an httptest server, which is valid for
+	// 127.0.0.1.
+	https := httptest.NewTLSServer(nil)
+	https.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(https.Certificate())
+
+	f := &fakeFTP{
+		t:        t,
+		implicit: mode == TLSImplicit,
+		noEPSV:   noEPSV,
+		dirs:     map[string]bool{"/": true, "/home": true},
+		files:    map[string][]byte{},
+	}
+	if mode != TLSNone {
+		f.tls = &tls.Config{Certificates: https.TLS.Certificates}
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, l.Addr().String(), roots
+}
+
+func (f *fakeFTP) file(name string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, ok := f.files[name]
+	return b, ok
+}
+
+// session is the state of one control connection.
+type session struct {
+	f        *fakeFTP
+	conn     net.Conn
+	r        *bufio.Reader
+	cwd      string
+	loggedIn bool
+	user     string
+	protP    bool
+	data     net.Listener
+}
+
+func (s *session) reply(format string, args ...any) {
+	fmt.Fprintf(s.conn, format+"\r\n", args...)
+}
+
+func (s *session) resolve(p string) string {
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join(s.cwd, p)
+	}
+	return path.Clean(p)
+}
+
+func (f *fakeFTP) serve(conn net.Conn) {
+	if f.implicit {
+		conn = tls.Server(conn, f.tls)
+	}
+	s := &session{f: f, conn: conn, r: bufio.NewReader(conn), cwd: "/home"}
+	defer func() {
+		conn.Close()
+		if s.data != nil {
+			s.data.Close()
+		}
+	}()
+
+	s.reply("220 fake FTP ready")
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		cmd = strings.ToUpper(cmd)
+		if !s.loggedIn && cmd != "AUTH" && cmd != "USER" && cmd != "PASS" && cmd != "QUIT" {
+			s.reply("530 not logged in")
+			continue
+		}
+		if !s.handle(cmd, arg) {
+			return
+		}
+	}
+}
+
+// handle runs one command and returns false if the connection should close.
+func (s *session) handle(cmd, arg string) bool {
+	f := s.f
+	switch cmd {
+	case "AUTH":
+		if f.tls == nil || f.implicit {
+			s.reply("502 no TLS")
+			return true
+		}
+		s.reply("234 go ahead")
+		s.conn = tls.Server(s.conn, f.tls)
+		s.r = bufio.NewReader(s.conn)
+	case "USER":
+		s.user = arg
+		s.reply("331 password please")
+	case "PASS":
+		if s.user != testUser || arg != testPass {
+			s.reply("530 login incorrect")
+			return true
+		}
+		if f.tls != nil && !f.implicit {
+			if _, ok := s.conn.(*tls.Conn); !ok {
+				s.reply("530 TLS required")
+				return true
+			}
+		}
+		s.loggedIn = true
+		s.reply("230 logged in")
+	case "FEAT":
+		s.reply("211-Features:\r\n UTF8\r\n211 End")
+	case "OPTS", "TYPE", "PBSZ":
+		s.reply("200 ok")
+	case "PROT":
+		s.protP = arg == "P"
+		s.reply("200 ok")
+	case "PWD":
+		s.reply(`257 "%s" is the current directory`, s.cwd)
+	case "CWD":
+		dir := s.resolve(arg)
+		f.mu.Lock()
+		ok := f.dirs[dir]
+		f.mu.Unlock()
+		if !ok {
+			s.reply("550 no such directory")
+			return true
+		}
+		s.cwd = dir
+		s.reply("250 ok")
+	case "MKD":
+		dir := s.resolve(arg)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.dirs[dir] || !f.dirs[path.Dir(dir)] {
+			s.reply("550 can't create directory")
+			return true
+		}
+		f.dirs[dir] = true
+		s.reply(`257 "%s" created`, dir)
+	case "EPSV", "PASV":
+		if cmd == "EPSV" && f.noEPSV {
+			s.reply("502 not implemented")
+			return true
+		}
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			s.reply("425 %v", err)
+			return true
+		}
+		if s.data != nil {
+			s.data.Close()
+		}
+		s.data = l
+		port := l.Addr().(*net.TCPAddr).Port
+		if cmd == "EPSV" {
+			s.reply("229 Entering Extended Passive Mode (|||%d|)", port)
+		} else {
+			s.reply("227 Entering Passive Mode (127,0,0,1,%d,%d)", port/256, port%256)
+		}
+	case "STOR":
+		name := s.resolve(arg)
+		f.mu.Lock()
+		ok := f.dirs[path.Dir(name)]
+		f.mu.Unlock()
+		if !ok || s.data == nil {
+			s.reply("550 can't store")
+			return true
+		}
+		s.reply("150 ok to send data")
+		conn, err := s.data.Accept()
+		s.data.Close()
+		s.data = nil
+		if err != nil {
+			s.reply("425 %v", err)
+			return true
+		}
+		if s.protP {
+			conn = tls.Server(conn, f.tls)
+		}
+		b, err := io.ReadAll(conn)
+		conn.Close()
+		f.mu.Lock()
+		f.files[name] = b
+		f.mu.Unlock()
+		if err != nil {
+			s.reply("426 transfer aborted")
+			return true
+		}
+		s.reply("226 transfer complete")
+	case "DELE":
+		name := s.resolve(arg)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.files[name]; !ok {
+			s.reply("550 no such file")
+			return true
+		}
+		delete(f.files, name)
+		s.reply("250 deleted")
+	case "QUIT":
+		s.reply("221 bye")
+		return false
+	default:
+		s.reply("502 not implemented")
+	}
+	return true
+}
+
+func newTestProvider(t *testing.T, addr string, roots *x509.CertPool, config Config) *Provider {
+	t.Helper()
+	config.Host = addr
+	config.User = testUser
+	if config.Password == "" {
+		config.Password = testPass
+	}
+	p, err := NewProvider(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.tlsConfig != nil {
+		p.tlsConfig.RootCAs = roots
+	}
+	return p
+}
+
+func TestUploadModes(t *testing.T) {
+	tests := []struct {
+		name   string
+		tls    string
+		noEPSV bool
+	}{
+		{"plain", TLSNone, false},
+		{"pasv", TLSNone, true},
+		{"explicit", TLSExplicit, false},
+		{"implicit", TLSImplicit, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, addr, roots := newFakeFTP(t, tt.tls, tt.noEPSV)
+			p := newTestProvider(t, addr, roots, Config{
+				TLS:          tt.tls,
+				DisableEPSV:  tt.noEPSV,
+				RemoteDir:    "public/drop 1",
+				LinkTemplate: "https://example.com/{{.Dir}}/{{.Name}}",
+			})
+			ctx := context.Background()
+
+			var wg sync.WaitGroup
+			for i := range 3 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					content := make([]byte, 100*1024+i)
+					rand.Read(content)
+					name := fmt.Sprintf("a b%d.bin", i)
+					id, err := p.Upload(ctx, bytes.NewReader(content), name, int64(len(content)))
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if got, _ := f.file("/home/public/drop 1/" + name); !bytes.Equal(got, content) {
+						t.Errorf("%s: stored %d bytes, want %d", name, len(got), len(content))
+					}
+					link, _ := p.GetLink(ctx, id)
+					if want := fmt.Sprintf("https://example.com/public/drop%%201/a%%20b%d.bin", i); link != want {
+						t.Errorf("link = %s, want %s", link, want)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if err := p.Delete(ctx, "a b0.bin"); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := f.file("/home/public/drop 1/a b0.bin"); ok {
+				t.Error("file still exists")
+			}
+		})
+	}
+}
+
+// cancelReader cancels the upload after the first chunk.
+type cancelReader struct {
+	cancel context.CancelFunc
+	reads  int
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	r.reads++
+	if r.reads > 1 {
+		r.cancel()
+	}
+	return copy(p, "partial"), nil
+}
+
+func TestUploadCancelRemovesPartialFile(t *testing.T) {
+	f, addr, roots := newFakeFTP(t, TLSNone, false)
+	p := newTestProvider(t, addr, roots, Config{})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, err := p.Upload(ctx, &cancelReader{cancel: cancel}, "big.bin", 1<<30)
+	if err == nil {
+		t.Fatal("upload succeeded")
+	}
+	if _, ok := f.file("/home/big.bin"); ok {
+		t.Error("partial file was not removed")
+	}
+}
+
+func TestWrongPassword(t *testing.T) {
+	_, addr, roots := newFakeFTP(t, TLSExplicit, false)
+	p := newTestProvider(t, addr, roots, Config{TLS: TLSExplicit, Password: "wrong"})
+	_, err := p.Upload(context.Background(), strings.NewReader("x"), "a.txt", 1)
+	if err == nil || !strings.Contains(err.Error(), "530") {
+		t.Errorf("err = %v, want 530", err)
+	}
+}
+
+func TestUploadExistingParent(t *testing.T) {
+	for _, dir := range []string{"public/drop", "/home/public/drop"} {
+		f, addr, roots := newFakeFTP(t, TLSNone, false)
+		f.dirs["/home/public"] = true
+		p := newTestProvider(t, addr, roots, Config{TLS: TLSNone, RemoteDir: dir})
+		if _, err := p.Upload(context.Background(), strings.NewReader("x"), "a.txt", 1); err != nil {
+			t.Fatalf("%s: %v", dir, err)
+		}
+		if got, _ := f.file("/home/public/drop/a.txt"); string(got) != "x" {
+			t.Errorf("%s: stored %q", dir, got)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	_, addr, roots := newFakeFTP(t, TLSExplicit, false)
+	if err := newTestProvider(t, addr, roots, Config{TLS: TLSExplicit}).Check(context.Background()); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+	err := newTestProvider(t, addr, roots, Config{TLS: TLSExplicit, Password: "wrong"}).Check(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "530") {
+		t.Errorf("err = %v, want 530", err)
+	}
+}
+
+func TestUntrustedCertificate(t *testing.T) {
+	_, addr, _ := newFakeFTP(t, TLSImplicit, false)
+	p := newTestProvider(t, addr, x509.NewCertPool(), Config{TLS: TLSImplicit})
+	_, err := p.Upload(context.Background(), strings.NewReader("x"), "a.txt", 1)
+	if err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("err = %v, want certificate error", err)
+	}
+}
+
+func TestDefaultLink(t *testing.T) {
+	p, err := NewProvider(Config{Host: "ftp.example.com", RemoteDir: "/pub/share"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, _ := p.GetLink(context.Background(), "a b.txt")
+	if want := "ftp://ftp.example.com/pub/share/a%20b.txt"; link != want {
+		t.Errorf("link = %s, want %s", link, want)
+	}
+}
@@ -0,0 +1,230 @@
+package gist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultAPIURL is the API of github.com; GitHub Enterprise uses
+	// https://<host>/api/v3.
+	DefaultAPIURL = "https://api.github.com"
+
+	// maxFileSize is the largest file put into a gist.
+	maxFileSize = 10 * 1024 * 1024
+)
+
+// Link types.
+const (
+	LinkHTML = "html"
+	LinkRaw  = "raw"
+)
+
+// LinkTypes lists all link types.
+var LinkTypes = []string{LinkHTML, LinkRaw}
+
+// Config describes the account and the kind of gists created.
+type Config struct {
+	// Token is a personal access token with the gist scope.
+	Token string
+	// APIURL defaults to DefaultAPIURL.
+	APIURL string
+	// Public creates public gists; by default they are secret (unlisted).
+	Public      bool
+	Description string
+	// Link is LinkHTML for the gist page or LinkRaw for the file content.
+	Link string
+	// Separate creates one gist per file instead of putting all files of
+	// one share into one gist.
+	Separate bool
+}
+
+// Provider shares text files as gists. Files uploaded through the same
+// Provider end up in one gist unless Config.Separate is set.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	// mu serializes uploads: the first creates the gist, later ones add
+	// their file to it.
+	mu     sync.Mutex
+	gistID string
+}
+
+// NewProvider creates a new gist provider.
+func NewProvider(config Config) (*Provider, error) {
+	if config.Token == "" {
+		return nil, errors.New("gist: token is required")
+	}
+	if config.APIURL == "" {
+		config.APIURL = DefaultAPIURL
+	}
+	config.APIURL = strings.TrimRight(config.APIURL, "/")
+	switch config.Link {
+	case LinkHTML, LinkRaw:
+	case "":
+		config.Link = LinkHTML
+	default:
+		return nil, fmt.Errorf("gist: unknown link type: %s", config.Link)
+	}
+	return &Provider{config: config, client: http.DefaultClient}, nil
+}
+
+// gist is the part of the API's gist object used here.
+type gist struct {
+	ID      string `json:"id"`
+	HTMLURL string `json:"html_url"`
+	Files   map[string]struct {
+		RawURL string `json:"raw_url"`
+	} `json:"files"`
+}
+
+// file is a file in a create or update request. A nil *file removes it.
+type file struct {
+	Content string `json:"content"`
+}
+
+// do sends a JSON request to the API and decodes the reply into out, if
+// not nil.
+func (p *Provider) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.config.APIURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.config.Token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(b, &e) != nil || e.Message == "" {
+			e.Message = string(bytes.TrimSpace(b))
+		}
+		return fmt.Errorf("gist: %s %s failed (%d): %s", method, path, resp.StatusCode, e.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Check implements provider.Checker by fetching the user of the token.
+func (p *Provider) Check(ctx context.Context) error {
+	return p.do(ctx, "GET", "/user", nil, nil)
+}
+
+// Upload adds the text file to the gist of this share, creating it on the
+// first upload. The file ID is "<gist id>/<filename>".
+func (p *Provider) Upload(ctx context.Context, r io.Reader, filename string, size int64) (string, error) {
+	content, err := io.ReadAll(io.LimitReader(r, maxFileSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(content) > maxFileSize {
+		return "", fmt.Errorf("gist: %s is larger than %d MiB", filename, maxFileSize>>20)
+	}
+	if !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0 {
+		return "", fmt.Errorf("gist: %s is not a text file", filename)
+	}
+	files := map[string]*file{filename: {Content: string(content)}}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.gistID != "" && !p.config.Separate {
+		if err := p.do(ctx, "PATCH", "/gists/"+p.gistID, map[string]any{"files": files}, nil); err != nil {
+			return "", err
+		}
+		return p.gistID + "/" + filename, nil
+	}
+
+	var g gist
+	err = p.do(ctx, "POST", "/gists", map[string]any{
+		"description": p.config.Description,
+		"public":      p.config.Public,
+		"files":       files,
+	}, &g)
+	if err != nil {
+		return "", err
+	}
+	if g.ID == "" {
+		return "", errors.New("gist: reply has no gist id")
+	}
+	p.gistID = g.ID
+	return g.ID + "/" + filename, nil
+}
+
+// splitID splits a file ID into gist ID and filename.
+func splitID(fileID string) (id, filename string, err error) {
+	id, filename, ok := strings.Cut(fileID, "/")
+	if !ok || id == "" || filename == "" {
+		return "", "", fmt.Errorf("gist: invalid file id %q", fileID)
+	}
+	return id, filename, nil
+}
+
+// GetLink returns the page of the gist or the raw URL of the file.
+func (p *Provider) GetLink(ctx context.Context, fileID string) (string, error) {
+	id, filename, err := splitID(fileID)
+	if err != nil {
+		return "", err
+	}
+	var g gist
+	if err := p.do(ctx, "GET", "/gists/"+id, nil, &g); err != nil {
+		return "", err
+	}
+	if p.config.Link == LinkHTML {
+		return g.HTMLURL, nil
+	}
+	f, ok := g.Files[filename]
+	if !ok || f.RawURL == "" {
+		return "", fmt.Errorf("gist: %s has no file %s", id, filename)
+	}
+	return f.RawURL, nil
+}
+
+// Delete removes the file from its gist, or the gist if it is the last file.
+func (p *Provider) Delete(ctx context.Context, fileID string) error {
+	id, filename, err := splitID(fileID)
+	if err != nil {
+		return err
+	}
+	var g gist
+	if err := p.do(ctx, "GET", "/gists/"+id, nil, &g); err != nil {
+		return err
+	}
+	if _, ok := g.Files[filename]; !ok {
+		return fmt.Errorf("gist: %s has no file %s", id, filename)
+	}
+	if len(g.Files) > 1 {
+		files := map[string]*file{filename: nil}
+		return p.do(ctx, "PATCH", "/gists/"+id, map[string]any{"files": files}, nil)
+	}
+	return p.do(ctx, "DELETE", "/gists/"+id, nil, nil)
+}
@@ -0,0 +1,226 @@
+package gist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testToken = "ghp_test"
+
+// fakeGitHub is an in-memory gists API.
+type fakeGitHub struct {
+	srv *httptest.Server
+
+	mu     sync.Mutex
+	nextID int
+	gists  map[string]*fakeGist
+}
+
+type fakeGist struct {
+	public bool
+	files  map[string]string
+}
+
+func newFakeGitHub(t *testing.T) *fakeGitHub {
+	f := &fakeGitHub{gists: map[string]*fakeGist{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /gists", f.create)
+	mux.HandleFunc("GET /gists/{id}", f.get)
+	mux.HandleFunc("PATCH /gists/{id}", f.update)
+	mux.HandleFunc("DELETE /gists/{id}", f.delete)
+	mux.HandleFunc("GET /user", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"login":"me"}`)
+	})
+	f.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message":"Bad credentials"}`)
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeGitHub) reply(w http.ResponseWriter, id string) {
+	g := f.gists[id]
+	files := map[string]any{}
+	for name := range g.files {
+		files[name] = map[string]string{"raw_url": "https://gist.example/raw/" + id + "/rev/" + name}
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"id":       id,
+		"html_url": "https://gist.example/" + id,
+		"files":    files,
+	})
+}
+
+type request struct {
+	Public *bool            `json:"public"`
+	Files  map[string]*file `json:"files"`
+}
+
+func (f *fakeGitHub) create(w http.ResponseWriter, r *http.Request) {
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Public == nil || len(req.Files) == 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+	f.nextID++
+	id := fmt.Sprintf("g%d", f.nextID)
+	g := &fakeGist{public: *req.Public, files: map[string]string{}}
+	for name, file := range req.Files {
+		g.files[name] = file.Content
+	}
+	f.gists[id] = g
+	w.WriteHeader(http.StatusCreated)
+	f.reply(w, id)
+}
+
+func (f *fakeGitHub) get(w http.ResponseWriter, r *http.Request) {
+	if f.gists[r.PathValue("id")] == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	f.reply(w, r.PathValue("id"))
+}
+
+func (f *fakeGitHub) update(w http.ResponseWriter, r *http.Request) {
+	g := f.gists[r.PathValue("id")]
+	var req request
+	if g == nil || json.NewDecoder(r.Body).Decode(&req) != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	for name, file := range req.Files {
+		if file == nil {
+			delete(g.files, name)
+		} else {
+			g.files[name] = file.Content
+		}
+	}
+	f.reply(w, r.PathValue("id"))
+}
+
+func (f *fakeGitHub) delete(w http.ResponseWriter, r *http.Request) {
+	delete(f.gists, r.PathValue("id"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newTestProvider(t *testing.T, f *fakeGitHub, config Config) *Provider {
+	t.Helper()
+	config.Token = testToken
+	config.APIURL = f.srv.URL + "/"
+	p, err := NewProvider(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestMultiFileGist(t *testing.T) {
+	f := newFakeGitHub(t)
+	p := newTestProvider(t, f, Config{})
+	ctx := context.Background()
+
+	names := []string{"a.go", "b.py", "c.txt"}
+	ids := make([]string, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			ids[i], err = p.Upload(ctx, strings.NewReader("content of "+name), name, -1)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(f.gists) != 1 {
+		t.Fatalf("%d gists, want 1", len(f.gists))
+	}
+	g := f.gists["g1"]
+	if g.public || len(g.files) != 3 || g.files["b.py"] != "content of b.py" {
+		t.Errorf("gist = %+v", g)
+	}
+	for _, id := range ids {
+		link, err := p.GetLink(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if link != "https://gist.example/g1" {
+			t.Errorf("link = %s", link)
+		}
+	}
+
+	if err := p.Delete(ctx, "g1/a.go"); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.files) != 2 {
+		t.Errorf("files after delete: %v", g.files)
+	}
+	p.Delete(ctx, "g1/b.py")
+	if err := p.Delete(ctx, "g1/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.gists) != 0 {
+		t.Error("gist not deleted with its last file")
+	}
+}
+
+func TestSeparatePublicRaw(t *testing.T) {
+	f := newFakeGitHub(t)
+	p := newTestProvider(t, f, Config{Separate: true, Public: true, Link: LinkRaw})
+	ctx := context.Background()
+
+	for i, name := range []string{"a.md", "b.md"} {
+		id, err := p.Upload(ctx, strings.NewReader("# "+name), name, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		link, err := p.GetLink(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("https://gist.example/raw/g%d/rev/%s", i+1, name); link != want {
+			t.Errorf("link = %s, want %s", link, want)
+		}
+	}
+	if len(f.gists) != 2 || !f.gists["g1"].public {
+		t.Errorf("gists = %v", f.gists)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	f := newFakeGitHub(t)
+	p := newTestProvider(t, f, Config{})
+	ctx := context.Background()
+
+	if _, err := p.Upload(ctx, strings.NewReader("\x00\x01binary"), "a.bin", -1); err == nil || !strings.Contains(err.Error(), "not a text file") {
+		t.Errorf("binary: err = %v", err)
+	}
+
+	p.config.Token = "wrong"
+	if _, err := p.Upload(ctx, strings.NewReader("x"), "a.txt", 1); err == nil || !strings.Contains(err.Error(), "(401): Bad credentials") {
+		t.Errorf("bad token: err = %v", err)
+	}
+	if err := p.Check(ctx); err == nil || !strings.Contains(err.Error(), "(401): Bad credentials") {
+		t.Errorf("Check with bad token: err = %v", err)
+	}
+	p.config.Token = testToken
+	if err := p.Check(ctx); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+}
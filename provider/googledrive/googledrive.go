@@ -13,6 +13,7 @@ import (
 	"log"
 	"net/http"
 	"path/filepath"
+	"sync"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -28,6 +29,13 @@ type Provider struct {
 	token          *oauth2.Token
 	tokenSource    oauth2.TokenSource
 	onTokenRefresh func(newToken *oauth2.Token)
+
+	// tokenMu guards token, replaced by the refresh callback.
+	tokenMu sync.Mutex
+
+	// folderMu guards folderID, which is looked up once per run.
+	folderMu sync.Mutex
+	folderID string
 }
 
 var mimeExtentions = map[string]string{
@@ -78,11 +86,11 @@ func OAuth2GoogleDriveConfig() *oauth2.Config {
 }
 
 // NewProvider creates a new Provider
-func NewProvider(token string) *Provider {
+func NewProvider(token string) (*Provider, error) {
 	tok := &oauth2.Token{}
 	err := json.Unmarshal([]byte(token), tok)
 	if err != nil {
-		log.Fatalf("Unable to parse config file: %v", err)
+		return nil, fmt.Errorf("unable to parse Google Drive token: %w", err)
 	}
 
 	cfg := OAuth2GoogleDriveConfig()
@@ -91,15 +99,18 @@ func NewProvider(token string) *Provider {
 		Config: cfg,
 	}
 	p.tokenSource = &notifyingTokenSource{
-		src: cfg.TokenSource(context.Background(), tok),
+		src:  cfg.TokenSource(context.Background(), tok),
+		last: tok.AccessToken,
 		onRefresh: func(newToken *oauth2.Token) {
+			p.tokenMu.Lock()
 			p.token = newToken
+			p.tokenMu.Unlock()
 			if p.onTokenRefresh != nil {
 				p.onTokenRefresh(newToken)
 			}
 		},
 	}
-	return p
+	return p, nil
 }
 
 // SetTokenRefreshCallback sets a callback that's invoked when the token is refreshed
@@ -109,39 +120,57 @@ func (c *Provider) SetTokenRefreshCallback(callback func(newToken *oauth2.Token)
 
 // GetCurrentToken returns the current (possibly refreshed) token
 func (c *Provider) GetCurrentToken() *oauth2.Token {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
 	return c.token
 }
 
-func (c *Provider) getClient() *http.Client {
-	return oauth2.NewClient(context.Background(), c.tokenSource)
+func (c *Provider) getClient(ctx context.Context) *http.Client {
+	return oauth2.NewClient(ctx, c.tokenSource)
 }
 
 // notifyingTokenSource wraps a TokenSource and calls a callback on token refresh
 type notifyingTokenSource struct {
 	src       oauth2.TokenSource
 	onRefresh func(*oauth2.Token)
+
+	// mu serializes Token for parallel uploads; last is the access token
+	// seen before, so onRefresh only runs when it changes.
+	mu   sync.Mutex
+	last string
 }
 
 func (n *notifyingTokenSource) Token() (*oauth2.Token, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
 	token, err := n.src.Token()
 	if err != nil {
 		return nil, err
 	}
+	if token.AccessToken == n.last {
+		return token, nil
+	}
+	n.last = token.AccessToken
 	if n.onRefresh != nil {
 		n.onRefresh(token)
 	}
 	return token, nil
 }
 
-// Upload the file
-func (c *Provider) Upload(r io.Reader, filename string, size int64) (fileID string, err error) {
-	client := c.getClient()
-	srv, err := drive.NewService(context.Background(), option.WithHTTPClient(client))
+// Upload the file. The media upload is streamed in chunks, so size may be
+// provider.UnknownSize. Drive only creates the file once the (resumable)
+// media upload has finished, so a cancelled upload leaves nothing behind.
+func (c *Provider) Upload(ctx context.Context, r io.Reader, filename string, size int64) (fileID string, err error) {
+	client := c.getClient(ctx)
+	srv, err := drive.NewService(ctx, option.WithHTTPClient(client))
 	if err != nil {
-		log.Fatalf("Unable to retrieve Drive client: %v", err)
+		return "", fmt.Errorf("unable to retrieve Drive client: %w", err)
 	}
 
-	parendID := getOrCreateFolder(srv, "sharecmd")
+	parendID, err := c.sharecmdFolder(ctx, srv)
+	if err != nil {
+		return "", err
+	}
 
 	fileext := filepath.Ext(filename)
 
@@ -152,7 +181,7 @@ func (c *Provider) Upload(r io.Reader, filename string, size int64) (fileID stri
 	if mimeExtentions[fileext] != "" {
 		f.MimeType = mimeExtentions[fileext]
 	}
-	result, err := srv.Files.Create(f).Media(r).Do()
+	result, err := srv.Files.Create(f).Media(r).Context(ctx).Do()
 	if err != nil {
 		return "", err
 	}
@@ -160,13 +189,13 @@ func (c *Provider) Upload(r io.Reader, filename string, size int64) (fileID stri
 }
 
 // GetLink for fileid
-func (c *Provider) GetLink(filepath string) (string, error) {
+func (c *Provider) GetLink(ctx context.Context, filepath string) (string, error) {
 	fileID := filepath
 
-	client := c.getClient()
-	srv, err := drive.NewService(context.Background(), option.WithHTTPClient(client))
+	client := c.getClient(ctx)
+	srv, err := drive.NewService(ctx, option.WithHTTPClient(client))
 	if err != nil {
-		log.Fatalf("Unable to retrieve Drive client: %v", err)
+		return "", fmt.Errorf("unable to retrieve Drive client: %w", err)
 	}
 
 	permission := &drive.Permission{
@@ -174,7 +203,7 @@ func (c *Provider) GetLink(filepath string) (string, error) {
 		Role: "reader",
 	}
 
-	_, err = srv.Permissions.Create(fileID, permission).Do()
+	_, err = srv.Permissions.Create(fileID, permission).Context(ctx).Do()
 	if err != nil {
 		return "", err
 	}
@@ -184,30 +213,70 @@ func (c *Provider) GetLink(filepath string) (string, error) {
 	return link, nil
 }
 
-func getOrCreateFolder(d *drive.Service, folderName string) string {
-	folderID := ""
+// Delete removes the file from Drive.
+func (c *Provider) Delete(ctx context.Context, fileID string) error {
+	srv, err := drive.NewService(ctx, option.WithHTTPClient(c.getClient(ctx)))
+	if err != nil {
+		return fmt.Errorf("unable to retrieve Drive client: %w", err)
+	}
+	return srv.Files.Delete(fileID).Context(ctx).Do()
+}
+
+// Revoke removes the "anyone with the link" reader permission added by GetLink.
+func (c *Provider) Revoke(ctx context.Context, fileID string) error {
+	srv, err := drive.NewService(ctx, option.WithHTTPClient(c.getClient(ctx)))
+	if err != nil {
+		return fmt.Errorf("unable to retrieve Drive client: %w", err)
+	}
+	return srv.Permissions.Delete(fileID, "anyoneWithLink").Context(ctx).Do()
+}
+
+// Check implements provider.Checker by fetching the account info, which
+// refreshes an expired token.
+func (c *Provider) Check(ctx context.Context) error {
+	srv, err := drive.NewService(ctx, option.WithHTTPClient(c.getClient(ctx)))
+	if err != nil {
+		return err
+	}
+	_, err = srv.About.Get().Fields("user").Context(ctx).Do()
+	return err
+}
+
+func (c *Provider) sharecmdFolder(ctx context.Context, srv *drive.Service) (string, error) {
+	c.folderMu.Lock()
+	defer c.folderMu.Unlock()
+	if c.folderID != "" {
+		return c.folderID, nil
+	}
+	id, err := getOrCreateFolder(ctx, srv, "sharecmd")
+	if err != nil {
+		return "", err
+	}
+	c.folderID = id
+	return id, nil
+}
+
+func getOrCreateFolder(ctx context.Context, d *drive.Service, folderName string) (string, error) {
 	if folderName == "" {
 		folderName = "sharecmd"
 	}
 	q := fmt.Sprintf("name=\"%s\" and mimeType=\"application/vnd.google-apps.folder\"", folderName)
 
-	r, err := d.Files.List().Q(q).Do()
+	r, err := d.Files.List().Q(q).Context(ctx).Do()
 	if err != nil {
-		log.Fatalf("Unable to retrieve foldername: %s", err.Error())
+		return "", fmt.Errorf("unable to retrieve foldername: %w", err)
 	}
 	if len(r.Files) > 0 {
-		folderID = r.Files[0].Id
-	} else {
-		// no folder found create new
-		log.Printf("Folder not found. Create new folder : %s\n", folderName)
-		f := &drive.File{Name: folderName, Description: "Auto Create by sharecmd", MimeType: "application/vnd.google-apps.folder"}
-		r, err := d.Files.Create(f).Do()
-		if err != nil {
-			log.Printf("An error occurred when create folder: %v\n", err)
-		}
-		folderID = r.Id
-	}
-	return folderID
+		return r.Files[0].Id, nil
+	}
+	// no folder found create new
+	log.Printf("Folder not found. Create new folder : %s\n", folderName)
+	f := &drive.File{Name: folderName, Description: "Auto Create by sharecmd", MimeType: "application/vnd.google-apps.folder"}
+	folder, err := d.Files.Create(f).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("an error occurred when create folder: %w", err)
+	}
+	return folder.Id, nil
 }
 
 type obf struct {
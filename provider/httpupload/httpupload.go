@@ -2,6 +2,7 @@ package httpupload
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +10,8 @@ import (
 	"strings"
 	"text/template"
 	"time"
+
+	"schneider.vip/share/provider"
 )
 
 // Provider uploads files via HTTP PUT to a base URL.
@@ -61,23 +64,25 @@ func renderValue(val string) string {
 	return buf.String()
 }
 
-// Upload PUTs the file content to baseURL/filename.
-func (p *Provider) Upload(r io.Reader, filename string, size int64) (string, error) {
+// Upload PUTs the file content to baseURL/filename. Content of unknown size
+// is sent with chunked transfer encoding.
+// If ctx is cancelled mid-upload, a DELETE is sent to remove a partial file.
+func (p *Provider) Upload(ctx context.Context, r io.Reader, filename string, size int64) (string, error) {
 	url := p.BaseURL + filename
 
-	req, err := http.NewRequest("PUT", url, r)
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, r)
 	if err != nil {
 		return "", err
 	}
 	req.ContentLength = size
 	req.Header.Set("Content-Type", "application/octet-stream")
-
-	for k, v := range p.Headers {
-		req.Header.Set(k, renderValue(v))
-	}
+	p.setHeaders(req)
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
+		if ctx.Err() != nil {
+			p.removePartial(ctx, url)
+		}
 		return "", err
 	}
 	defer resp.Body.Close()
@@ -91,6 +96,51 @@ func (p *Provider) Upload(r io.Reader, filename string, size int64) (string, err
 }
 
 // GetLink returns the URL that was already constructed during Upload.
-func (p *Provider) GetLink(fileURL string) (string, error) {
+func (p *Provider) GetLink(ctx context.Context, fileURL string) (string, error) {
 	return fileURL, nil
 }
+
+// Delete sends an HTTP DELETE for the uploaded file.
+func (p *Provider) Delete(ctx context.Context, fileURL string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", fileURL, nil)
+	if err != nil {
+		return err
+	}
+	p.setHeaders(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("HTTP DELETE failed (%d): %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (p *Provider) setHeaders(req *http.Request) {
+	for k, v := range p.Headers {
+		req.Header.Set(k, renderValue(v))
+	}
+}
+
+// removePartial deletes whatever the server may have stored of an aborted
+// upload. Servers that do not support DELETE simply reject it.
+func (p *Provider) removePartial(ctx context.Context, url string) {
+	ctx, cancel := provider.CleanupContext(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
+	if err != nil {
+		return
+	}
+	p.setHeaders(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,140 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sethvargo/go-password/password"
+)
+
+// ErrUnsupported is returned (wrapped) when a provider cannot enforce a
+// requested link option.
+var ErrUnsupported = errors.New("not supported by this provider")
+
+// LinkOptions are optional settings for a public link.
+type LinkOptions struct {
+	// Expire is how long the link stays valid; 0 means it never expires.
+	Expire time.Duration
+	// Password protects the link if set.
+	Password string
+}
+
+// IsZero reports whether no option is set.
+func (o LinkOptions) IsZero() bool {
+	return o == LinkOptions{}
+}
+
+// String names the options that are set, e.g. "expiry and password", for
+// error messages.
+func (o LinkOptions) String() string {
+	var names []string
+	if o.Expire > 0 {
+		names = append(names, "expiry")
+	}
+	if o.Password != "" {
+		names = append(names, "password")
+	}
+	return strings.Join(names, " and ")
+}
+
+// ExpiresAt returns the expiry time relative to now, or the zero time.
+func (o LinkOptions) ExpiresAt(now time.Time) time.Time {
+	if o.Expire <= 0 {
+		return time.Time{}
+	}
+	return now.Add(o.Expire)
+}
+
+// ExpireDays returns Expire in whole days, rounded up, for backends that
+// only accept a number of days or a date.
+func (o LinkOptions) ExpireDays() int {
+	if o.Expire <= 0 {
+		return 0
+	}
+	day := 24 * time.Hour
+	return int((o.Expire + day - 1) / day)
+}
+
+// Link is a public link created with LinkOptions.
+type Link struct {
+	URL string
+	// Expires is when the link stops working, or the zero time.
+	Expires time.Time
+	// Password is needed to open the link, if it is protected. Providers
+	// that generate a password themselves return it here.
+	Password string
+}
+
+// LinkCreator is implemented by providers that support LinkOptions.
+type LinkCreator interface {
+	// CheckLinkOptions returns an error wrapping ErrUnsupported if the
+	// options cannot be enforced, which may depend on the account. It is
+	// called before uploading, so that nothing is sent when the link could
+	// not be created as requested.
+	CheckLinkOptions(ctx context.Context, opts LinkOptions) error
+	// CreateLink creates a public link for the file ID with opts applied.
+	CreateLink(ctx context.Context, fileID string, opts LinkOptions) (*Link, error)
+}
+
+// CheckLinkOptions reports whether p can create links with opts.
+func CheckLinkOptions(ctx context.Context, p Provider, opts LinkOptions) error {
+	if opts.IsZero() {
+		return nil
+	}
+	lc, ok := p.(LinkCreator)
+	if !ok {
+		return fmt.Errorf("link options: %w", ErrUnsupported)
+	}
+	return lc.CheckLinkOptions(ctx, opts)
+}
+
+// CreateLink creates a link with opts, falling back to GetLink for providers
+// without LinkOptions support when no options are set.
+func CreateLink(ctx context.Context, p Provider, fileID string, opts LinkOptions) (*Link, error) {
+	if lc, ok := p.(LinkCreator); ok {
+		return lc.CreateLink(ctx, fileID, opts)
+	}
+	if err := CheckLinkOptions(ctx, p, opts); err != nil {
+		return nil, err
+	}
+	url, err := p.GetLink(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	return &Link{URL: url}, nil
+}
+
+// passwordDigits is the number of digits in a generated password.
+const passwordDigits = 4
+
+// GeneratePassword returns a random password of the given length made of
+// letters and digits, so it can be typed and pasted without quoting.
+func GeneratePassword(length int) (string, error) {
+	return password.Generate(length, min(passwordDigits, length), 0, false, true)
+}
+
+// ParseExpire parses an expiry like "7d", "2w", "12h" or any
+// time.ParseDuration value. An empty string means no expiry.
+func ParseExpire(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	unit := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if u, ok := unit[s[len(s)-1]]; ok {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid expiry %q", s)
+		}
+		return time.Duration(n) * u, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid expiry %q (use e.g. 7d, 2w or 12h)", s)
+	}
+	return d, nil
+}
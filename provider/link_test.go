@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"testing"
+	"time"
+	"unicode"
+)
+
+func TestParseExpire(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":    0,
+		"7d":  7 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"90m": 90 * time.Minute,
+	}
+	for in, want := range tests {
+		got, err := ParseExpire(in)
+		if err != nil {
+			t.Errorf("ParseExpire(%q): %v", in, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseExpire(%q) = %v, want %v", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"d", "-1d", "0h", "soon"} {
+		if _, err := ParseExpire(in); err == nil {
+			t.Errorf("ParseExpire(%q) should fail", in)
+		}
+	}
+}
+
+func TestExpireDays(t *testing.T) {
+	tests := map[time.Duration]int{
+		0:              0,
+		time.Hour:      1,
+		24 * time.Hour: 1,
+		25 * time.Hour: 2,
+	}
+	for d, want := range tests {
+		if got := (LinkOptions{Expire: d}).ExpireDays(); got != want {
+			t.Errorf("ExpireDays(%v) = %d, want %d", d, got, want)
+		}
+	}
+}
+
+func TestGeneratePassword(t *testing.T) {
+	pw, err := GeneratePassword(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pw) != 16 {
+		t.Errorf("len = %d, want 16", len(pw))
+	}
+	for _, r := range pw {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			t.Errorf("unexpected character %q in %q", r, pw)
+		}
+	}
+}
+
+func TestLinkOptionsString(t *testing.T) {
+	opts := LinkOptions{Expire: time.Hour, Password: "secret"}
+	if got := opts.String(); got != "expiry and password" {
+		t.Errorf("String() = %q", got)
+	}
+}
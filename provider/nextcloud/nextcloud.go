@@ -1,14 +1,18 @@
 package nextcloud
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/sethvargo/go-password/password"
+	"schneider.vip/share/provider"
 )
 
 type Config struct {
@@ -21,18 +25,21 @@ type Config struct {
 
 type Provider struct {
 	config Config
+	now    func() time.Time
 }
 
 func NewProvider(c Config) *Provider {
-	return &Provider{config: c}
+	return &Provider{config: c, now: time.Now}
 }
 
-func (s *Provider) Upload(r io.Reader, filename string, size int64) (string, error) {
-	if err := s.createFolder("sharecmd"); err != nil {
-		fmt.Printf("could not create folder: %s\n", err.Error())
+func (s *Provider) Upload(ctx context.Context, r io.Reader, filename string, size int64) (string, error) {
+	if err := s.createFolder(ctx, "sharecmd"); err != nil {
+		// Not fatal: the folder usually exists already. Logged to stderr to
+		// keep stdout clean for --output json and plain.
+		log.Printf("Warning: could not create folder: %v\n", err)
 	}
 
-	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/remote.php/webdav/sharecmd/%s", s.config.URL, filename), r)
+	req, err := http.NewRequestWithContext(ctx, "PUT", s.fileURL(filename), r)
 	if err != nil {
 		return "", err
 	}
@@ -42,40 +49,203 @@ func (s *Provider) Upload(r io.Reader, filename string, size int64) (string, err
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
+		if ctx.Err() != nil {
+			cctx, cancel := provider.CleanupContext(ctx)
+			defer cancel()
+			s.deleteFile(cctx, filename) //nolint:errcheck
+		}
 		return "", err
 	}
 	defer resp.Body.Close()
 	return filename, nil
 }
 
-func (s *Provider) GetLink(filename string) (r string, err error) {
-	if s.config.LinkShareWithPassword {
-		randompw, pwerr := password.Generate(s.config.RandomPasswordChars, 1, 1, false, false)
-		if pwerr != nil {
-			return "", err
+func (s *Provider) fileURL(filename string) string {
+	return fmt.Sprintf("%s/remote.php/webdav/sharecmd/%s", s.config.URL, filename)
+}
+
+// deleteFile removes sharecmd/filename via WebDAV.
+func (s *Provider) deleteFile(ctx context.Context, filename string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", s.fileURL(filename), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.config.Username, s.config.Password)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("delete failed (%d)", resp.StatusCode)
+	}
+	return nil
+}
+
+// Delete removes the uploaded file, which also removes its shares.
+func (s *Provider) Delete(ctx context.Context, filename string) error {
+	return s.deleteFile(ctx, filename)
+}
+
+// Revoke deletes all public link shares (shareType=3) of the file.
+func (s *Provider) Revoke(ctx context.Context, filename string) error {
+	q := url.Values{"path": {"sharecmd/" + filename}}
+	req, err := http.NewRequestWithContext(ctx, "GET", s.sharesURL()+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.config.Username, s.config.Password)
+	req.Header.Set("OCS-APIRequest", "true")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var reply struct {
+		XMLName xml.Name `xml:"ocs"`
+		Meta    struct {
+			Status  string `xml:"status"`
+			Message string `xml:"message"`
+		} `xml:"meta"`
+		Data struct {
+			Elements []struct {
+				ID        string `xml:"id"`
+				ShareType string `xml:"share_type"`
+			} `xml:"element"`
+		} `xml:"data"`
+	}
+	if err := xml.Unmarshal(b, &reply); err != nil {
+		return err
+	}
+	if reply.Meta.Status != "ok" {
+		return fmt.Errorf("Status: %s, Message: %s", reply.Meta.Status, reply.Meta.Message)
+	}
+
+	for _, share := range reply.Data.Elements {
+		if share.ShareType != "3" {
+			continue
+		}
+		if err := s.deleteShare(ctx, share.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Provider) deleteShare(ctx context.Context, id string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", s.sharesURL()+"/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.config.Username, s.config.Password)
+	req.Header.Set("OCS-APIRequest", "true")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("delete share %s failed (%d)", id, resp.StatusCode)
+	}
+	return nil
+}
+
+// Check implements provider.Checker by reading the properties of the WebDAV
+// root with the configured credentials.
+func (s *Provider) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "PROPFIND", s.config.URL+"/remote.php/webdav/", nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.config.Username, s.config.Password)
+	req.Header.Set("Depth", "0")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("invalid username or password")
+	case resp.StatusCode != http.StatusMultiStatus:
+		return fmt.Errorf("PROPFIND failed (%d)", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *Provider) sharesURL() string {
+	return fmt.Sprintf("%s/ocs/v1.php/apps/files_sharing/api/v1/shares", s.config.URL)
+}
+
+func (s *Provider) GetLink(ctx context.Context, filename string) (string, error) {
+	link, err := s.CreateLink(ctx, filename, provider.LinkOptions{})
+	if err != nil {
+		return "", err
+	}
+	return link.URL, nil
+}
+
+// CheckLinkOptions implements provider.LinkCreator. OCS shares support an
+// expiry date and a password.
+func (s *Provider) CheckLinkOptions(ctx context.Context, opts provider.LinkOptions) error {
+	return nil
+}
+
+// CreateLink creates a public link share. Since OCS only accepts an
+// expireDate, the share expires at the first midnight not before the
+// requested expiry, which is returned in the Link. Without a password in
+// opts a random one is generated if LinkShareWithPassword is set; it is
+// returned in the Link as well.
+func (s *Provider) CreateLink(ctx context.Context, filename string, opts provider.LinkOptions) (*provider.Link, error) {
+	expires := opts.ExpiresAt(s.now())
+	if !expires.IsZero() {
+		y, m, d := expires.Date()
+		midnight := time.Date(y, m, d, 0, 0, 0, 0, expires.Location())
+		if midnight.Before(expires) {
+			midnight = midnight.AddDate(0, 0, 1)
 		}
-		r, err = s.getLink(filename, randompw)
-		if err == nil {
-			fmt.Println("=======================================")
-			fmt.Printf("Password generated: %s\n", randompw)
-			fmt.Println("=======================================")
+		expires = midnight
+	}
+
+	pass := opts.Password
+	if pass == "" && s.config.LinkShareWithPassword {
+		randompw, err := password.Generate(s.config.RandomPasswordChars, 1, 1, false, false)
+		if err != nil {
+			return nil, err
 		}
-	} else {
-		r, err = s.getLink(filename, "")
+		pass = randompw
 	}
 
-	return
+	u, err := s.getLink(ctx, filename, pass, expires)
+	if err != nil {
+		return nil, err
+	}
+	return &provider.Link{URL: u, Expires: expires, Password: pass}, nil
 }
 
-func (s *Provider) getLink(filename string, pass string) (string, error) {
-	var body *strings.Reader
-	if pass == "" {
-		body = strings.NewReader(fmt.Sprintf(`path=sharecmd/%s&shareType=3&permissions=1`, filename))
-	} else {
-		body = strings.NewReader(fmt.Sprintf(`path=sharecmd/%s&shareType=3&permissions=1&password=%s`, filename, url.QueryEscape(pass)))
+func (s *Provider) getLink(ctx context.Context, filename string, pass string, expires time.Time) (string, error) {
+	form := url.Values{
+		"path":        {"sharecmd/" + filename},
+		"shareType":   {"3"},
+		"permissions": {"1"},
+	}
+	if pass != "" {
+		form.Set("password", pass)
+	}
+	if !expires.IsZero() {
+		form.Set("expireDate", expires.Format(time.DateOnly))
 	}
+	body := strings.NewReader(form.Encode())
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/ocs/v1.php/apps/files_sharing/api/v1/shares", s.config.URL), body)
+	req, err := http.NewRequestWithContext(ctx, "POST", s.sharesURL(), body)
 	if err != nil {
 		return "", err
 	}
@@ -144,10 +314,10 @@ func (s *Provider) getLink(filename string, pass string) (string, error) {
 	return reply.Data.URL, nil
 }
 
-func (s *Provider) createFolder(foldername string) error {
+func (s *Provider) createFolder(ctx context.Context, foldername string) error {
 	url := fmt.Sprintf("%s/remote.php/dav/files/%s/%s", s.config.URL, s.config.Username, foldername)
 
-	req, err := http.NewRequest("MKCOL", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "MKCOL", url, nil)
 	if err != nil {
 		return err
 	}
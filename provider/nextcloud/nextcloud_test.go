@@ -0,0 +1,58 @@
+package nextcloud
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"schneider.vip/share/provider"
+)
+
+func TestCreateLinkExpiry(t *testing.T) {
+	var expireDate string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/ocs/v1.php/apps/files_sharing/api/v1/shares" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		expireDate = r.PostFormValue("expireDate")
+		w.Write([]byte(`<ocs><meta><status>ok</status></meta><data><url>https://cloud.example/s/abc</url></data></ocs>`))
+	}))
+	defer srv.Close()
+
+	p := NewProvider(Config{URL: srv.URL, Username: "me", Password: "pw"})
+	for _, tc := range []struct {
+		now    time.Time
+		expire time.Duration
+		want   string
+	}{
+		// An hour before midnight, one hour must not end after 30 minutes.
+		{time.Date(2024, 5, 1, 23, 30, 0, 0, time.UTC), time.Hour, "2024-05-03"},
+		{time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), time.Hour, "2024-05-02"},
+		{time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), 24 * time.Hour, "2024-05-02"},
+		{time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), 0, ""},
+	} {
+		p.now = func() time.Time { return tc.now }
+		link, err := p.CreateLink(context.Background(), "a.txt", provider.LinkOptions{Expire: tc.expire})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expireDate != tc.want {
+			t.Errorf("%v + %v: expireDate = %q, want %q", tc.now, tc.expire, expireDate, tc.want)
+		}
+		if tc.want == "" {
+			if !link.Expires.IsZero() {
+				t.Errorf("no expiry: Expires = %v", link.Expires)
+			}
+			continue
+		}
+		if got := link.Expires.Format(time.DateOnly); got != tc.want {
+			t.Errorf("%v + %v: Expires = %v, want %s", tc.now, tc.expire, link.Expires, tc.want)
+		}
+		if link.Expires.Before(tc.now.Add(tc.expire)) {
+			t.Errorf("%v + %v: Expires %v is too early", tc.now, tc.expire, link.Expires)
+		}
+	}
+}
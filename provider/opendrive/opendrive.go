@@ -2,30 +2,42 @@ package opendrive
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"sync"
+
+	"schneider.vip/share/provider"
 )
 
+const apiBase = "https://dev.opendrive.com/api/v1"
+
 // NewProvider creates a new Provider
 func NewProvider(user, pass string) *Provider {
 	return &Provider{Username: user, Passwd: pass}
 }
 
 type Provider struct {
-	Username     string `json:"username"`
-	Passwd       string `json:"passwd"`
-	downloadlink string
+	Username string `json:"username"`
+	Passwd   string `json:"passwd"`
+
+	// folderMu lets one upload at a time find or create the folder.
+	folderMu sync.Mutex
+
+	// links maps file IDs returned by Upload to their download links.
+	linksMu sync.Mutex
+	links   map[string]string
 }
 
-func (o *Provider) getSessionID() (string, error) {
+func (o *Provider) getSessionID(ctx context.Context) (string, error) {
 	body, err := json.Marshal(o)
 	if err != nil {
 		return "", err
 	}
-	resp, err := http.Post("https://dev.opendrive.com/api/v1/session/login.json", "application/json", bytes.NewReader(body))
+	resp, err := post(ctx, apiBase+"/session/login.json", "application/json", bytes.NewReader(body))
 	if err != nil {
 		return "", err
 	}
@@ -50,7 +62,7 @@ func (o *Provider) getSessionID() (string, error) {
 	return response.SessionID, nil
 }
 
-func (o *Provider) createFolder(sessionid string) (string, error) {
+func (o *Provider) createFolder(ctx context.Context, sessionid string) (string, error) {
 	type Props struct {
 		SessionID  string `json:"session_id"`
 		FolderName string `json:"folder_name"`
@@ -65,7 +77,7 @@ func (o *Provider) createFolder(sessionid string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	resp, err := http.Post("https://dev.opendrive.com/api/v1/folder.json", "application/json", bytes.NewReader(body))
+	resp, err := post(ctx, apiBase+"/folder.json", "application/json", bytes.NewReader(body))
 	if err != nil {
 		return "", err
 	}
@@ -86,7 +98,7 @@ func (o *Provider) createFolder(sessionid string) (string, error) {
 	return response.FolderID, nil
 }
 
-func (o *Provider) getFolderID(sessionid string) (string, error) {
+func (o *Provider) getFolderID(ctx context.Context, sessionid string) (string, error) {
 	type Props struct {
 		SessionID string `json:"session_id"`
 		Path      string `json:"path"`
@@ -101,7 +113,7 @@ func (o *Provider) getFolderID(sessionid string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	resp, err := http.Post("https://dev.opendrive.com/api/v1/folder/idbypath.json", "application/json", bytes.NewReader(body))
+	resp, err := post(ctx, apiBase+"/folder/idbypath.json", "application/json", bytes.NewReader(body))
 	if err != nil {
 		return "", err
 	}
@@ -125,7 +137,7 @@ func (o *Provider) getFolderID(sessionid string) (string, error) {
 }
 
 // createFile returns the fileId if sucessful
-func (o *Provider) createFile(sessionid, folderid, filename string) (fileid string, downloadlink string, err error) {
+func (o *Provider) createFile(ctx context.Context, sessionid, folderid, filename string) (fileid string, downloadlink string, err error) {
 	type Props struct {
 		SessionID    string `json:"session_id"`
 		FolderID     string `json:"folder_id"`
@@ -144,7 +156,7 @@ func (o *Provider) createFile(sessionid, folderid, filename string) (fileid stri
 	if err != nil {
 		return "", "", err
 	}
-	resp, err := http.Post("https://dev.opendrive.com/api/v1/upload/create_file.json", "application/json", bytes.NewReader(body))
+	resp, err := post(ctx, apiBase+"/upload/create_file.json", "application/json", bytes.NewReader(body))
 	if err != nil {
 		return "", "", err
 	}
@@ -169,7 +181,7 @@ func (o *Provider) createFile(sessionid, folderid, filename string) (fileid stri
 	return response.FileId, response.DownloadLink, nil
 }
 
-func (o *Provider) openfileUpload(sessionid, fileID, fileName string, r io.Reader, size int64) (string, error) {
+func (o *Provider) openfileUpload(ctx context.Context, sessionid, fileID, fileName string, r io.Reader, size int64) (string, error) {
 	type Props struct {
 		SessionID    string `json:"session_id"`
 		FileID       string `json:"file_id"`
@@ -186,7 +198,7 @@ func (o *Provider) openfileUpload(sessionid, fileID, fileName string, r io.Reade
 	if err != nil {
 		return "", err
 	}
-	resp, err := http.Post("https://dev.opendrive.com/api/v1/upload/open_file_upload.json", "application/json", bytes.NewReader(body))
+	resp, err := post(ctx, apiBase+"/upload/open_file_upload.json", "application/json", bytes.NewReader(body))
 	if err != nil {
 		return "", err
 	}
@@ -231,7 +243,7 @@ func (o *Provider) openfileUpload(sessionid, fileID, fileName string, r io.Reade
 		return "", err
 	}
 
-	resp2, err := http.Post("https://dev.opendrive.com/api/v1/upload/upload_file_chunk.json", writer.FormDataContentType(), body2)
+	resp2, err := post(ctx, apiBase+"/upload/upload_file_chunk.json", writer.FormDataContentType(), body2)
 	if err != nil {
 		return "", err
 	}
@@ -239,7 +251,7 @@ func (o *Provider) openfileUpload(sessionid, fileID, fileName string, r io.Reade
 	props.TempLocation = response.TempLocation
 	body, _ = json.Marshal(props)
 
-	resp3, err := http.Post("https://dev.opendrive.com/api/v1/upload/close_file_upload.json", "application/json", bytes.NewReader(body))
+	resp3, err := post(ctx, apiBase+"/upload/close_file_upload.json", "application/json", bytes.NewReader(body))
 	if err != nil {
 		return "", err
 	}
@@ -264,10 +276,12 @@ func (o *Provider) openfileUpload(sessionid, fileID, fileName string, r io.Reade
 	return response3.DownloadLink, nil
 }
 
-func (o *Provider) getOrCreateFolderID(sessionid string) (string, error) {
-	fid, err := o.getFolderID(sessionid)
+func (o *Provider) getOrCreateFolderID(ctx context.Context, sessionid string) (string, error) {
+	o.folderMu.Lock()
+	defer o.folderMu.Unlock()
+	fid, err := o.getFolderID(ctx, sessionid)
 	if err != nil {
-		fid, err = o.createFolder(sessionid)
+		fid, err = o.createFolder(ctx, sessionid)
 		if err != nil {
 			return "", err
 		}
@@ -276,32 +290,109 @@ func (o *Provider) getOrCreateFolderID(sessionid string) (string, error) {
 	return fid, nil
 }
 
-func (o *Provider) Upload(r io.Reader, filename string, size int64) (string, error) {
-	sid, err := o.getSessionID()
+// Upload creates the file in the sharecmd folder and uploads its content in a
+// single chunk. If ctx is cancelled, the half-created file is moved to trash.
+// Content of unknown size is spooled to a temporary file first, since
+// OpenDrive needs the file size when the upload is opened.
+func (o *Provider) Upload(ctx context.Context, r io.Reader, filename string, size int64) (string, error) {
+	if size == provider.UnknownSize {
+		f, n, cleanup, err := provider.Spool(r)
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		r, size = f, n
+	}
+
+	sid, err := o.getSessionID(ctx)
 	if err != nil {
 		return "", err
 	}
-	folderID, err := o.getOrCreateFolderID(sid)
+	folderID, err := o.getOrCreateFolderID(ctx, sid)
 	if err != nil {
 		return "", err
 	}
 
-	fileid, _, err := o.createFile(sid, folderID, filename)
+	fileid, _, err := o.createFile(ctx, sid, folderID, filename)
 	if err != nil {
 		return "", err
 	}
 
-	downloadlink, err := o.openfileUpload(sid, fileid, filename, r, size)
+	downloadlink, err := o.openfileUpload(ctx, sid, fileid, filename, r, size)
 	if err != nil {
+		if ctx.Err() != nil {
+			cctx, cancel := provider.CleanupContext(ctx)
+			defer cancel()
+			o.trashFile(cctx, sid, fileid) //nolint:errcheck
+		}
 		return "", err
 	}
-	o.downloadlink = downloadlink
+
+	o.linksMu.Lock()
+	defer o.linksMu.Unlock()
+	if o.links == nil {
+		o.links = make(map[string]string)
+	}
+	o.links[fileid] = downloadlink
+	return fileid, nil
+}
+
+// trashFile moves a file to the OpenDrive trash.
+func (o *Provider) trashFile(ctx context.Context, sessionid, fileID string) error {
+	type Props struct {
+		SessionID string `json:"session_id"`
+		FileID    string `json:"file_id"`
+	}
+
+	body, err := json.Marshal(Props{SessionID: sessionid, FileID: fileID})
+	if err != nil {
+		return err
+	}
+	resp, err := post(ctx, apiBase+"/file/trash.json", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		resultBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("trash failed (%d): %s", resp.StatusCode, string(resultBody))
+	}
+	return nil
+}
+
+// GetLink returns the download link that close_file_upload returned for the
+// file ID during Upload.
+func (o *Provider) GetLink(ctx context.Context, fileID string) (string, error) {
+	o.linksMu.Lock()
+	defer o.linksMu.Unlock()
+	downloadlink := o.links[fileID]
+	if len(downloadlink) == 0 {
+		return "", fmt.Errorf("no download link for file %s", fileID)
+	}
 	return downloadlink, nil
 }
 
-func (o *Provider) GetLink(string) (string, error) {
-	if len(o.downloadlink) == 0 {
-		return "", fmt.Errorf("failure")
+// Delete moves the file to the OpenDrive trash.
+func (o *Provider) Delete(ctx context.Context, fileID string) error {
+	sid, err := o.getSessionID(ctx)
+	if err != nil {
+		return err
+	}
+	return o.trashFile(ctx, sid, fileID)
+}
+
+// Check implements provider.Checker by logging in.
+func (o *Provider) Check(ctx context.Context) error {
+	_, err := o.getSessionID(ctx)
+	return err
+}
+
+// post is http.Post with a context.
+func post(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return nil, err
 	}
-	return o.downloadlink, nil
+	req.Header.Set("Content-Type", contentType)
+	return http.DefaultClient.Do(req)
 }
@@ -0,0 +1,83 @@
+package paste
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// languages maps file extensions to the language names highlight.js,
+// Pygments and most paste services understand.
+var languages = map[string]string{
+	".bash":       "bash",
+	".c":          "c",
+	".cc":         "cpp",
+	".cpp":        "cpp",
+	".cs":         "csharp",
+	".css":        "css",
+	".diff":       "diff",
+	".go":         "go",
+	".h":          "c",
+	".hpp":        "cpp",
+	".html":       "html",
+	".ini":        "ini",
+	".java":       "java",
+	".js":         "javascript",
+	".json":       "json",
+	".kt":         "kotlin",
+	".lua":        "lua",
+	".md":         "markdown",
+	".patch":      "diff",
+	".php":        "php",
+	".pl":         "perl",
+	".ps1":        "powershell",
+	".py":         "python",
+	".rb":         "ruby",
+	".rs":         "rust",
+	".scala":      "scala",
+	".sh":         "bash",
+	".sql":        "sql",
+	".swift":      "swift",
+	".tf":         "hcl",
+	".toml":       "toml",
+	".ts":         "typescript",
+	".tsx":        "typescript",
+	".xml":        "xml",
+	".yaml":       "yaml",
+	".yml":        "yaml",
+	".zsh":        "bash",
+	".dockerfile": "dockerfile",
+}
+
+// names maps well-known file names without a telling extension.
+var names = map[string]string{
+	"dockerfile":     "dockerfile",
+	"makefile":       "makefile",
+	"gnumakefile":    "makefile",
+	"cmakelists.txt": "cmake",
+	"go.mod":         "go",
+}
+
+// PlainText is the language of files that are not recognized, e.g. logs.
+const PlainText = "text"
+
+// Language returns the syntax highlighting language for filename, or
+// PlainText.
+func Language(filename string) string {
+	base := strings.ToLower(filepath.Base(filename))
+	if lang, ok := names[base]; ok {
+		return lang
+	}
+	if lang, ok := languages[filepath.Ext(base)]; ok {
+		return lang
+	}
+	return PlainText
+}
+
+// extension returns the extension of filename without the dot, "txt" if it
+// has none. Services like hastebin highlight by the extension in the link.
+func extension(filename string) string {
+	if ext := strings.TrimPrefix(filepath.Ext(filename), "."); ext != "" {
+		return ext
+	}
+	return "txt"
+}
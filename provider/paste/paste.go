@@ -0,0 +1,338 @@
+package paste
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// Request body encodings.
+const (
+	// BodyForm sends a multipart/form-data upload, like curl -F.
+	BodyForm = "form"
+	// BodyRaw sends the content itself as body.
+	BodyRaw = "raw"
+	// BodyJSON sends a JSON object with the content as string field.
+	BodyJSON = "json"
+)
+
+// Presets.
+const (
+	Preset0x0        = "0x0.st"
+	PresetTransferSh = "transfer.sh"
+	PresetHastebin   = "hastebin"
+	PresetCustom     = "custom"
+)
+
+// Presets lists all presets.
+var Presets = []string{Preset0x0, PresetTransferSh, PresetHastebin, PresetCustom}
+
+// DefaultMaxJSONSize limits the content of JSON pastes, which are built in
+// memory.
+const DefaultMaxJSONSize = 10 * 1024 * 1024
+
+// Config describes a paste endpoint. URL, Fields, Headers and LinkTemplate
+// are templates executed with Data.
+type Config struct {
+	// Server is the base URL of the service, e.g. https://hastebin.example.com.
+	Server string
+	// URL is the endpoint, e.g. {{.Server}}/documents.
+	URL string
+	// Method is POST (the default) or PUT.
+	Method string
+	// Body is one of BodyForm, BodyRaw and BodyJSON.
+	Body string
+	// Field is the form field or JSON key that holds the content; the
+	// default is "file" for forms and "content" for JSON.
+	Field string
+	// Fields are additional form fields or JSON keys, e.g.
+	// {"language": "{{.Language}}"}.
+	Fields  map[string]string
+	Headers map[string]string
+	// LinkField is the dotted path of the link (or a key) in a JSON reply,
+	// e.g. "data.url". If empty, the reply body is the link.
+	LinkField string
+	// LinkTemplate builds the link from the reply, e.g.
+	// {{.Server}}/{{.Value}}.{{.Ext}}. If empty, the reply is the link.
+	LinkTemplate string
+	// MaxSize limits the content of JSON pastes, DefaultMaxJSONSize if 0.
+	MaxSize int64
+}
+
+// Data is passed to the templates of Config.
+type Data struct {
+	// Server is Config.Server without trailing slash.
+	Server string
+	// Name is the path-escaped filename, RawName the filename itself.
+	Name    string
+	RawName string
+	// Language is the detected syntax highlighting language, see Language.
+	Language string
+	// Ext is the filename extension without dot, "txt" if there is none.
+	Ext string
+	// Value is the reply or the LinkField of the reply; only set for
+	// LinkTemplate.
+	Value string
+}
+
+// PresetConfig returns the configuration of a well-known service. server
+// overrides the service's public instance.
+func PresetConfig(preset, server string) (Config, error) {
+	var c Config
+	switch preset {
+	case Preset0x0:
+		c = Config{Server: "https://0x0.st", URL: "{{.Server}}", Body: BodyForm, Field: "file"}
+	case PresetTransferSh:
+		c = Config{Server: "https://transfer.sh", URL: "{{.Server}}/{{.Name}}", Method: http.MethodPut, Body: BodyRaw}
+	case PresetHastebin:
+		c = Config{
+			URL:          "{{.Server}}/documents",
+			Body:         BodyRaw,
+			LinkField:    "key",
+			LinkTemplate: "{{.Server}}/{{.Value}}.{{.Ext}}",
+		}
+	default:
+		return c, fmt.Errorf("unknown paste preset: %s", preset)
+	}
+	if server != "" {
+		c.Server = server
+	}
+	if c.Server == "" {
+		return c, fmt.Errorf("%s needs a server URL", preset)
+	}
+	return c, nil
+}
+
+// Provider uploads text to a paste service.
+type Provider struct {
+	config    Config
+	url       *template.Template
+	link      *template.Template
+	fields    map[string]*template.Template
+	headers   map[string]*template.Template
+	client    *http.Client
+	userAgent string
+}
+
+// NewProvider creates a new paste provider.
+func NewProvider(config Config) (*Provider, error) {
+	if config.URL == "" {
+		return nil, errors.New("paste: URL is required")
+	}
+	config.Server = strings.TrimRight(config.Server, "/")
+	if config.Method == "" {
+		config.Method = http.MethodPost
+	}
+	switch config.Body {
+	case BodyForm, "":
+		config.Body = BodyForm
+		if config.Field == "" {
+			config.Field = "file"
+		}
+	case BodyJSON:
+		if config.Field == "" {
+			config.Field = "content"
+		}
+	case BodyRaw:
+	default:
+		return nil, fmt.Errorf("paste: unknown body encoding: %s", config.Body)
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = DefaultMaxJSONSize
+	}
+
+	p := &Provider{
+		config:    config,
+		fields:    map[string]*template.Template{},
+		headers:   map[string]*template.Template{},
+		client:    http.DefaultClient,
+		userAgent: "sharecmd",
+	}
+	var err error
+	if p.url, err = template.New("url").Parse(config.URL); err != nil {
+		return nil, fmt.Errorf("paste: invalid URL template: %w", err)
+	}
+	if config.LinkTemplate != "" {
+		if p.link, err = template.New("link").Parse(config.LinkTemplate); err != nil {
+			return nil, fmt.Errorf("paste: invalid link template: %w", err)
+		}
+	}
+	for k, v := range config.Fields {
+		if p.fields[k], err = template.New(k).Parse(v); err != nil {
+			return nil, fmt.Errorf("paste: invalid field %s: %w", k, err)
+		}
+	}
+	for k, v := range config.Headers {
+		if p.headers[k], err = template.New(k).Parse(v); err != nil {
+			return nil, fmt.Errorf("paste: invalid header %s: %w", k, err)
+		}
+	}
+	return p, nil
+}
+
+func execute(t *template.Template, data Data) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("%s template: %w", t.Name(), err)
+	}
+	return b.String(), nil
+}
+
+// body returns the request body and its content type. Form bodies are
+// streamed; JSON bodies are built in memory up to MaxSize.
+func (p *Provider) body(r io.Reader, data Data, fields map[string]string) (io.Reader, string, error) {
+	switch p.config.Body {
+	case BodyRaw:
+		return r, "text/plain; charset=utf-8", nil
+	case BodyJSON:
+		content, err := io.ReadAll(io.LimitReader(r, p.config.MaxSize+1))
+		if err != nil {
+			return nil, "", err
+		}
+		if int64(len(content)) > p.config.MaxSize {
+			return nil, "", fmt.Errorf("paste: content exceeds %d bytes", p.config.MaxSize)
+		}
+		obj := map[string]string{p.config.Field: string(content)}
+		for k, v := range fields {
+			obj[k] = v
+		}
+		b, err := json.Marshal(obj)
+		if err != nil {
+			return nil, "", err
+		}
+		return bytes.NewReader(b), "application/json", nil
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		err := func() error {
+			for k, v := range fields {
+				if err := mw.WriteField(k, v); err != nil {
+					return err
+				}
+			}
+			part, err := mw.CreateFormFile(p.config.Field, data.RawName)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(part, r); err != nil {
+				return err
+			}
+			return mw.Close()
+		}()
+		pw.CloseWithError(err)
+	}()
+	return pr, mw.FormDataContentType(), nil
+}
+
+// Upload sends the content to the paste service and returns the link.
+func (p *Provider) Upload(ctx context.Context, r io.Reader, filename string, size int64) (string, error) {
+	data := Data{
+		Server:   p.config.Server,
+		Name:     url.PathEscape(filename),
+		RawName:  filename,
+		Language: Language(filename),
+		Ext:      extension(filename),
+	}
+	endpoint, err := execute(p.url, data)
+	if err != nil {
+		return "", err
+	}
+	fields := map[string]string{}
+	for k, t := range p.fields {
+		if fields[k], err = execute(t, data); err != nil {
+			return "", err
+		}
+	}
+
+	body, contentType, err := p.body(r, data, fields)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, p.config.Method, endpoint, body)
+	if err != nil {
+		return "", err
+	}
+	if p.config.Body == BodyRaw {
+		req.ContentLength = size
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", p.userAgent)
+	for k, t := range p.headers {
+		v, err := execute(t, data)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	reply, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("paste: %s %s failed (%d): %s", req.Method, endpoint, resp.StatusCode, bytes.TrimSpace(reply))
+	}
+
+	data.Value = strings.TrimSpace(string(reply))
+	if p.config.LinkField != "" {
+		if data.Value, err = jsonField(reply, p.config.LinkField); err != nil {
+			return "", err
+		}
+	}
+	link := data.Value
+	if p.link != nil {
+		if link, err = execute(p.link, data); err != nil {
+			return "", err
+		}
+	}
+	if u, err := url.Parse(link); err != nil || !u.IsAbs() {
+		return "", fmt.Errorf("paste: reply is not a link: %q", link)
+	}
+	return link, nil
+}
+
+// jsonField returns the value at the dotted path in a JSON object.
+func jsonField(reply []byte, path string) (string, error) {
+	var v any
+	d := json.NewDecoder(bytes.NewReader(reply))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return "", fmt.Errorf("paste: invalid JSON reply: %w", err)
+	}
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("paste: reply has no field %s", path)
+		}
+		if v, ok = obj[key]; !ok {
+			return "", fmt.Errorf("paste: reply has no field %s", path)
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	}
+	return "", fmt.Errorf("paste: reply field %s is not a string", path)
+}
+
+// GetLink returns the link Upload returned.
+func (p *Provider) GetLink(ctx context.Context, link string) (string, error) {
+	return link, nil
+}
@@ -0,0 +1,173 @@
+package paste
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"schneider.vip/share/provider"
+)
+
+func TestLanguage(t *testing.T) {
+	tests := map[string]string{
+		"main.go":            "go",
+		"/tmp/Script.PY":     "python",
+		"fix.patch":          "diff",
+		"changes.diff":       "diff",
+		"Dockerfile":         "dockerfile",
+		"Makefile":           "makefile",
+		"build.log":          PlainText,
+		"notes.txt":          PlainText,
+		"stdin":              PlainText,
+		"docker-compose.yml": "yaml",
+	}
+	for name, want := range tests {
+		if got := Language(name); got != want {
+			t.Errorf("Language(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// fakeServices emulates the paste services the presets are made for.
+func fakeServices(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	// 0x0.st: multipart upload, the link is the reply.
+	mux.HandleFunc("POST /0x0", func(w http.ResponseWriter, r *http.Request) {
+		f, hdr, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		b, _ := io.ReadAll(f)
+		if r.UserAgent() != "sharecmd" {
+			http.Error(w, "bad user agent", http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, "https://0x0.example/"+hdr.Filename+"?"+string(b)+"\n")
+	})
+	// transfer.sh: raw PUT to /<name>, the link is the reply.
+	mux.HandleFunc("PUT /transfer/{name}", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		io.WriteString(w, "https://transfer.example/abc/"+r.PathValue("name")+"?"+string(b))
+	})
+	// hastebin: raw POST to /documents, replies with the key.
+	mux.HandleFunc("POST /haste/documents", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if string(b) != "package main\n" {
+			http.Error(w, "unexpected content", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"key":"xyz"}`)
+	})
+	// A JSON API in the style of PrivateBin-like services.
+	mux.HandleFunc("POST /json/api", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"status": 0,
+			"data":   map[string]any{"id": 42, "lang": req["syntax"], "text": req["text"]},
+		})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func upload(t *testing.T, config Config, content, name string) (string, error) {
+	t.Helper()
+	p, err := NewProvider(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := p.Upload(context.Background(), strings.NewReader(content), name, provider.UnknownSize)
+	if err != nil {
+		return "", err
+	}
+	return p.GetLink(context.Background(), id)
+}
+
+func TestPresets(t *testing.T) {
+	srv := fakeServices(t)
+	tests := []struct {
+		preset, server, content, name, want string
+	}{
+		{Preset0x0, srv.URL + "/0x0/", "hello", "a.txt", "https://0x0.example/a.txt?hello"},
+		{PresetTransferSh, srv.URL + "/transfer", "hi", "a b.log", "https://transfer.example/abc/a b.log?hi"},
+		{PresetHastebin, srv.URL + "/haste/", "package main\n", "main.go", srv.URL + "/haste/xyz.go"},
+	}
+	for _, tt := range tests {
+		config, err := PresetConfig(tt.preset, tt.server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		link, err := upload(t, config, tt.content, tt.name)
+		if err != nil {
+			t.Errorf("%s: %v", tt.preset, err)
+			continue
+		}
+		if link != tt.want {
+			t.Errorf("%s: link = %s, want %s", tt.preset, link, tt.want)
+		}
+	}
+
+	if _, err := PresetConfig(PresetHastebin, ""); err == nil {
+		t.Error("hastebin without server accepted")
+	}
+}
+
+func TestCustomJSON(t *testing.T) {
+	srv := fakeServices(t)
+	config := Config{
+		Server:       srv.URL,
+		URL:          "{{.Server}}/json/api",
+		Body:         BodyJSON,
+		Field:        "text",
+		Fields:       map[string]string{"syntax": "{{.Language}}"},
+		Headers:      map[string]string{"Authorization": "Bearer tok"},
+		LinkField:    "data.id",
+		LinkTemplate: "https://paste.example/{{.Value}}#{{.Language}}",
+	}
+	link, err := upload(t, config, "x = 1", "a.py")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://paste.example/42#python"; link != want {
+		t.Errorf("link = %s, want %s", link, want)
+	}
+
+	config.LinkField = "data.missing"
+	if _, err := upload(t, config, "x", "a.py"); err == nil || !strings.Contains(err.Error(), "no field data.missing") {
+		t.Errorf("missing field: err = %v", err)
+	}
+
+	config.LinkField = "data.id"
+	config.MaxSize = 3
+	if _, err := upload(t, config, "too long", "a.py"); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("max size: err = %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	srv := fakeServices(t)
+	_, err := upload(t, Config{Server: srv.URL, URL: "{{.Server}}/json/api", Body: BodyJSON}, "x", "a.txt")
+	if err == nil || !strings.Contains(err.Error(), "(401)") {
+		t.Errorf("err = %v, want 401", err)
+	}
+
+	// A reply that is not a link, e.g. an HTML page.
+	_, err = upload(t, Config{Server: srv.URL, URL: "{{.Server}}/haste/documents", Body: BodyRaw}, "package main\n", "a.go")
+	if err == nil || !strings.Contains(err.Error(), "not a link") {
+		t.Errorf("err = %v, want not a link", err)
+	}
+}
@@ -1,11 +1,105 @@
 package provider
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"os"
+	"time"
 )
 
-// Provider Interface...
+// UnknownSize is passed as size to Upload when the length of the content is
+// not known in advance, e.g. when reading from stdin.
+const UnknownSize int64 = -1
+
+// Provider is the v2 provider interface. All methods take a context so an
+// upload can be aborted (e.g. Ctrl+C in the progress UI), which cancels the
+// underlying HTTP requests.
 type Provider interface {
-	Upload(r io.Reader, filename string, size int64) (string, error)
-	GetLink(string) (string, error)
+	// Upload stores the content of r under filename and returns a
+	// provider-specific file ID that can be passed to GetLink. size is
+	// UnknownSize if the length of r is not known in advance.
+	Upload(ctx context.Context, r io.Reader, filename string, size int64) (string, error)
+	// GetLink creates (or looks up) a public link for the file ID.
+	GetLink(ctx context.Context, fileID string) (string, error)
+}
+
+// Deleter is implemented by providers that can remove an uploaded file,
+// which also invalidates its public link.
+type Deleter interface {
+	Delete(ctx context.Context, fileID string) error
+}
+
+// Revoker is implemented by providers that can remove the public link of a
+// file while keeping the file itself.
+type Revoker interface {
+	Revoke(ctx context.Context, fileID string) error
+}
+
+// Checker is implemented by providers that can verify their settings and
+// credentials with a cheap request, without uploading anything. An expired
+// OAuth token is refreshed on the way.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// Check runs the check of p, or returns an error wrapping ErrUnsupported if
+// p has none.
+func Check(ctx context.Context, p Provider) error {
+	c, ok := p.(Checker)
+	if !ok {
+		return fmt.Errorf("check: %w", ErrUnsupported)
+	}
+	return c.Check(ctx)
+}
+
+// cleanupTimeout bounds the best-effort removal of partial uploads.
+const cleanupTimeout = 30 * time.Second
+
+// CleanupContext returns a context for removing a partially uploaded file
+// after ctx was cancelled. It keeps the values of ctx but is not cancelled
+// together with it.
+func CleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+}
+
+// ContextReader returns a reader that fails with ctx.Err() once ctx is
+// cancelled, for protocols whose transfers take no context.
+func ContextReader(ctx context.Context, r io.Reader) io.Reader {
+	return &contextReader{ctx: ctx, r: r}
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// Spool copies r into a temporary file, for providers that need the content
+// length before they can start uploading. The returned cleanup function
+// closes and removes the file.
+func Spool(r io.Reader) (f *os.File, size int64, cleanup func(), err error) {
+	f, err = os.CreateTemp("", "sharecmd-*")
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	cleanup = func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	size, err = io.Copy(f, r)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, err
+	}
+	return f, size, cleanup, nil
 }
@@ -0,0 +1,349 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"schneider.vip/share/provider"
+)
+
+const (
+	// DefaultRegion is used when no region is configured. MinIO accepts it
+	// regardless of its own region setting.
+	DefaultRegion = "us-east-1"
+	// DefaultLinkExpiry is the lifetime of presigned links if none is set.
+	DefaultLinkExpiry = 7 * 24 * time.Hour
+	// MaxLinkExpiry is the longest lifetime SigV4 allows for presigned URLs.
+	MaxLinkExpiry = 7 * 24 * time.Hour
+
+	minPartSize = 5 * 1024 * 1024
+	maxParts    = 10000
+)
+
+// Config describes a bucket on AWS S3 or an S3-compatible server like MinIO.
+type Config struct {
+	// Endpoint is e.g. https://s3.eu-central-1.amazonaws.com or
+	// http://localhost:9000. If empty, the AWS endpoint of Region is used.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PathStyle addresses the bucket as <endpoint>/<bucket> instead of
+	// <bucket>.<endpoint>. Most self-hosted servers need it.
+	PathStyle bool
+	// LinkExpiry is the lifetime of the presigned URLs returned by GetLink.
+	LinkExpiry time.Duration
+}
+
+// Provider uploads to an S3 bucket and shares presigned GET URLs.
+type Provider struct {
+	config   Config
+	creds    credentials
+	endpoint *url.URL
+	client   *http.Client
+	partSize int64
+	now      func() time.Time
+}
+
+// NewProvider creates a new S3 provider.
+func NewProvider(config Config) (*Provider, error) {
+	if config.Bucket == "" {
+		return nil, errors.New("s3: no bucket configured")
+	}
+	if config.Region == "" {
+		config.Region = DefaultRegion
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", config.Region)
+	}
+	if config.LinkExpiry <= 0 {
+		config.LinkExpiry = DefaultLinkExpiry
+	}
+	if config.LinkExpiry > MaxLinkExpiry {
+		return nil, fmt.Errorf("s3: link expiry %s exceeds the maximum of 7 days", config.LinkExpiry)
+	}
+	endpoint, err := url.Parse(strings.TrimRight(config.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", config.Endpoint)
+	}
+	return &Provider{
+		config: config,
+		creds: credentials{
+			AccessKeyID:     config.AccessKeyID,
+			SecretAccessKey: config.SecretAccessKey,
+			Region:          config.Region,
+		},
+		endpoint: endpoint,
+		client:   http.DefaultClient,
+		partSize: 16 * 1024 * 1024,
+		now:      time.Now,
+	}, nil
+}
+
+// objectKey returns the key an upload named filename is stored under.
+func (p *Provider) objectKey(filename string) string {
+	prefix := strings.Trim(p.config.Prefix, "/")
+	if prefix == "" {
+		return filename
+	}
+	return prefix + "/" + filename
+}
+
+// objectURL returns the URL of key with the given query.
+func (p *Provider) objectURL(key string, query url.Values) *url.URL {
+	u := *p.endpoint
+	escapedKey := uriEncode(key, false)
+	if p.config.PathStyle {
+		u.RawPath = u.Path + "/" + uriEncode(p.config.Bucket, true) + "/" + escapedKey
+	} else {
+		u.Host = p.config.Bucket + "." + u.Host
+		u.RawPath = u.Path + "/" + escapedKey
+	}
+	u.Path, _ = url.PathUnescape(u.RawPath)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+// do signs and sends a request for key. The body must be fully buffered so
+// its hash can be signed. Replies with an error status are returned as error.
+func (p *Provider) do(ctx context.Context, method, key string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, p.objectURL(key, query).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, v := range header {
+		req.Header[name] = v
+	}
+	payloadHash := emptyPayload
+	if len(body) > 0 {
+		payloadHash = hexSHA256(body)
+	}
+	p.creds.sign(req, payloadHash, p.now())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, responseError(method, resp)
+	}
+	return resp, nil
+}
+
+// responseError turns an S3 error reply into an error.
+func responseError(method string, resp *http.Response) error {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(b, &e) == nil && e.Code != "" {
+		return fmt.Errorf("s3: %s %s failed (%d): %s: %s", method, resp.Request.URL.Path, resp.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Errorf("s3: %s %s failed (%d): %s", method, resp.Request.URL.Path, resp.StatusCode, string(b))
+}
+
+// Upload stores the content under Prefix/filename. Content that fits into a
+// single part is sent with one PUT, everything else as multipart upload.
+// A multipart upload is aborted if ctx is cancelled or a part fails.
+func (p *Provider) Upload(ctx context.Context, r io.Reader, filename string, size int64) (string, error) {
+	key := p.objectKey(filename)
+	header := http.Header{}
+	if ct := mime.TypeByExtension(path.Ext(filename)); ct != "" {
+		header.Set("Content-Type", ct)
+	}
+
+	partSize := p.partSize
+	if size > 0 && size/maxParts >= partSize {
+		partSize = size/maxParts + 1
+	}
+	buf := make([]byte, partSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		resp, err := p.do(ctx, "PUT", key, nil, buf[:n], header)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+		return key, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	uploadID, err := p.createMultipartUpload(ctx, key, header)
+	if err != nil {
+		return "", err
+	}
+	if err := p.uploadParts(ctx, key, uploadID, r, buf); err != nil {
+		cleanupCtx, cancel := provider.CleanupContext(ctx)
+		defer cancel()
+		p.abortMultipartUpload(cleanupCtx, key, uploadID)
+		return "", err
+	}
+	return key, nil
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (p *Provider) createMultipartUpload(ctx context.Context, key string, header http.Header) (string, error) {
+	resp, err := p.do(ctx, "POST", key, url.Values{"uploads": {""}}, nil, header)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("s3: can't parse CreateMultipartUpload reply: %w", err)
+	}
+	if result.UploadID == "" {
+		return "", errors.New("s3: CreateMultipartUpload returned no upload ID")
+	}
+	return result.UploadID, nil
+}
+
+// uploadParts sends buf (already filled with the first part) and the rest of
+// r as parts and completes the upload.
+func (p *Provider) uploadParts(ctx context.Context, key, uploadID string, r io.Reader, buf []byte) error {
+	var parts []completedPart
+	n := len(buf)
+	for {
+		partNumber := len(parts) + 1
+		if partNumber > maxParts {
+			return fmt.Errorf("s3: content exceeds %d parts", maxParts)
+		}
+		query := url.Values{
+			"partNumber": {strconv.Itoa(partNumber)},
+			"uploadId":   {uploadID},
+		}
+		resp, err := p.do(ctx, "PUT", key, query, buf[:n], nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		parts = append(parts, completedPart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+
+		var rerr error
+		n, rerr = io.ReadFull(r, buf)
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil && rerr != io.ErrUnexpectedEOF {
+			return rerr
+		}
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	resp, err := p.do(ctx, "POST", key, url.Values{"uploadId": {uploadID}}, body, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// CompleteMultipartUpload can fail after a 200 status; the error is
+	// in the body then.
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(b, &result) == nil && result.XMLName.Local == "Error" {
+		return fmt.Errorf("s3: CompleteMultipartUpload failed: %s: %s", result.Code, result.Message)
+	}
+	return nil
+}
+
+func (p *Provider) abortMultipartUpload(ctx context.Context, key, uploadID string) {
+	resp, err := p.do(ctx, "DELETE", key, url.Values{"uploadId": {uploadID}}, nil, nil)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+// GetLink returns a presigned GET URL for the object key, valid for
+// LinkExpiry.
+func (p *Provider) GetLink(ctx context.Context, key string) (string, error) {
+	link, err := p.CreateLink(ctx, key, provider.LinkOptions{})
+	if err != nil {
+		return "", err
+	}
+	return link.URL, nil
+}
+
+// CheckLinkOptions implements provider.LinkCreator. Presigned URLs expire
+// after at most 7 days and cannot have a password.
+func (p *Provider) CheckLinkOptions(ctx context.Context, opts provider.LinkOptions) error {
+	if opts.Password != "" {
+		return fmt.Errorf("password: %w", provider.ErrUnsupported)
+	}
+	if opts.Expire > MaxLinkExpiry {
+		return fmt.Errorf("expiry longer than 7 days: %w", provider.ErrUnsupported)
+	}
+	return nil
+}
+
+// CreateLink returns a presigned GET URL. opts.Expire overrides LinkExpiry.
+func (p *Provider) CreateLink(ctx context.Context, key string, opts provider.LinkOptions) (*provider.Link, error) {
+	if err := p.CheckLinkOptions(ctx, opts); err != nil {
+		return nil, err
+	}
+	expiry := p.config.LinkExpiry
+	if opts.Expire > 0 {
+		expiry = opts.Expire.Truncate(time.Second)
+	}
+	now := p.now()
+	return &provider.Link{
+		URL:     p.creds.presign(p.objectURL(key, nil), expiry, now),
+		Expires: now.Add(expiry),
+	}, nil
+}
+
+// Check implements provider.Checker by listing at most one object below
+// Prefix, which verifies the credentials and that the bucket exists.
+func (p *Provider) Check(ctx context.Context) error {
+	query := url.Values{"list-type": {"2"}, "max-keys": {"1"}, "prefix": {p.objectKey("")}}
+	resp, err := p.do(ctx, "GET", "", query, nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Delete removes the object. Presigned links to it stop working.
+func (p *Provider) Delete(ctx context.Context, key string) error {
+	resp, err := p.do(ctx, "DELETE", key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
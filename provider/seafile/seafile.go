@@ -2,15 +2,19 @@ package seafile
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/mschneider82/easygo"
+	"schneider.vip/share/provider"
 )
 
 type Config struct {
@@ -97,9 +101,12 @@ type Provider struct {
 	RepoID string
 }
 
-func (s *Provider) Upload(r io.Reader, filename string, size int64) (fileID string, err error) {
+// Upload sends the file to the library root. Seafile only commits a file once
+// the upload request completed, so a cancelled upload leaves nothing behind.
+// The multipart body is buffered, so size may be provider.UnknownSize.
+func (s *Provider) Upload(ctx context.Context, r io.Reader, filename string, size int64) (fileID string, err error) {
 	// get upload link
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api2/repos/%s/upload-link/?p=/&replace=1", s.URL, s.RepoID), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api2/repos/%s/upload-link/?p=/&replace=1", s.URL, s.RepoID), nil)
 	if err != nil {
 		return "", err
 	}
@@ -116,14 +123,14 @@ func (s *Provider) Upload(r io.Reader, filename string, size int64) (fileID stri
 	}
 	uploadLink := easygo.StringStrip(string(uploadLinkBroken), `"`)
 
-	_, err = uploadfile(uploadLink, "/", filename, s.Token, r)
+	_, err = uploadfile(ctx, uploadLink, "/", filename, s.Token, r)
 	if err != nil {
 		return "", err
 	}
 	return filename, nil
 }
 
-func uploadfile(uploadlink, folder, filename, token string, src io.Reader) (string, error) {
+func uploadfile(ctx context.Context, uploadlink, folder, filename, token string, src io.Reader) (string, error) {
 	requestbody := &bytes.Buffer{}
 	multipartWriter := multipart.NewWriter(requestbody)
 	part, err := multipartWriter.CreateFormFile("file", filename)
@@ -131,6 +138,9 @@ func uploadfile(uploadlink, folder, filename, token string, src io.Reader) (stri
 		return "", err
 	}
 	_, err = io.Copy(part, src)
+	if err != nil {
+		return "", err
+	}
 
 	multipartWriter.WriteField("filename", filename)
 	multipartWriter.WriteField("parent_dir", folder)
@@ -140,7 +150,7 @@ func uploadfile(uploadlink, folder, filename, token string, src io.Reader) (stri
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", uploadlink, requestbody)
+	req, err := http.NewRequestWithContext(ctx, "POST", uploadlink, requestbody)
 	if err != nil {
 		return "", err
 	}
@@ -164,9 +174,9 @@ func uploadfile(uploadlink, folder, filename, token string, src io.Reader) (stri
 	return string(responsebody), nil
 }
 
-func (s *Provider) GetLink(filepath string) (string, error) {
+func (s *Provider) GetLink(ctx context.Context, filepath string) (string, error) {
 	body := strings.NewReader(fmt.Sprintf(`p=/%s`, filepath))
-	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/api2/repos/%s/file/shared-link/", s.URL, s.RepoID), body)
+	req, err := http.NewRequestWithContext(ctx, "PUT", fmt.Sprintf("%s/api2/repos/%s/file/shared-link/", s.URL, s.RepoID), body)
 	if err != nil {
 		return "", err
 	}
@@ -187,6 +197,152 @@ func (s *Provider) GetLink(filepath string) (string, error) {
 	return url, nil
 }
 
+// CheckLinkOptions implements provider.LinkCreator. Share links support an
+// expiry in whole days and a password.
+func (s *Provider) CheckLinkOptions(ctx context.Context, opts provider.LinkOptions) error {
+	return nil
+}
+
+// CreateLink creates a share link with the given options. Without options it
+// is the same as GetLink.
+func (s *Provider) CreateLink(ctx context.Context, filepath string, opts provider.LinkOptions) (*provider.Link, error) {
+	if opts.IsZero() {
+		link, err := s.GetLink(ctx, filepath)
+		if err != nil {
+			return nil, err
+		}
+		return &provider.Link{URL: link}, nil
+	}
+
+	args := map[string]any{
+		"repo_id": s.RepoID,
+		"path":    "/" + filepath,
+	}
+	if days := opts.ExpireDays(); days > 0 {
+		args["expire_days"] = days
+	}
+	if opts.Password != "" {
+		args["password"] = opts.Password
+	}
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/api/v2.1/share-links/", s.URL), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var result struct {
+		Link       string `json:"link"`
+		ExpireDate string `json:"expire_date"`
+	}
+	if err := s.do(req, &result); err != nil {
+		return nil, err
+	}
+	link := &provider.Link{URL: fmt.Sprintf("%s?dl=1", result.Link), Password: opts.Password}
+	if t, err := time.Parse(time.RFC3339, result.ExpireDate); err == nil {
+		link.Expires = t
+	} else {
+		link.Expires = opts.ExpiresAt(time.Now())
+	}
+	return link, nil
+}
+
+// Delete removes the file from the library.
+func (s *Provider) Delete(ctx context.Context, filepath string) error {
+	q := url.Values{"p": {"/" + filepath}}
+	req, err := http.NewRequestWithContext(ctx, "DELETE", fmt.Sprintf("%s/api2/repos/%s/file/?%s", s.URL, s.RepoID, q.Encode()), nil)
+	if err != nil {
+		return err
+	}
+	return s.do(req, nil)
+}
+
+// Revoke deletes all share links of the file.
+func (s *Provider) Revoke(ctx context.Context, filepath string) error {
+	q := url.Values{"repo_id": {s.RepoID}, "path": {"/" + filepath}}
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/v2.1/share-links/?%s", s.URL, q.Encode()), nil)
+	if err != nil {
+		return err
+	}
+	var links []struct {
+		Token string `json:"token"`
+	}
+	if err := s.do(req, &links); err != nil {
+		return err
+	}
+
+	for _, l := range links {
+		req, err := http.NewRequestWithContext(ctx, "DELETE", fmt.Sprintf("%s/api/v2.1/share-links/%s/", s.URL, url.PathEscape(l.Token)), nil)
+		if err != nil {
+			return err
+		}
+		if err := s.do(req, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check implements provider.Checker by looking up the library RepoID with
+// the token.
+func (s *Provider) Check(ctx context.Context) error {
+	if s.RepoID == "" {
+		return fmt.Errorf("no library (repoid) configured")
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api2/repos/%s/", s.URL, url.PathEscape(s.RepoID)), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", s.Token))
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("invalid token (%d)", resp.StatusCode)
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("library %s not found", s.RepoID)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("library lookup failed (%d)", resp.StatusCode)
+	}
+	return nil
+}
+
+// do sends an authenticated API request and decodes a JSON reply into v
+// unless v is nil.
+func (s *Provider) do(req *http.Request, v any) error {
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", s.Token))
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	resultBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s %s failed (%d): %s", req.Method, req.URL.Path, resp.StatusCode, string(resultBody))
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resultBody, v); err != nil {
+		return fmt.Errorf("json unmarshal error: %s", err.Error())
+	}
+	return nil
+}
+
 func NewProvider(url, token, repoid string) *Provider {
 	return &Provider{URL: url, Token: token, RepoID: repoid}
 }
@@ -0,0 +1,271 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"schneider.vip/share/provider"
+)
+
+// Authentication methods.
+const (
+	AuthAgent    = "agent"
+	AuthKey      = "key"
+	AuthPassword = "password"
+)
+
+// AuthMethods lists all authentication methods.
+var AuthMethods = []string{AuthAgent, AuthKey, AuthPassword}
+
+// Config describes the SSH server, the directory uploads go to and the URL
+// that directory is served under.
+type Config struct {
+	// Host is host or host:port; the port defaults to 22.
+	Host string
+	User string
+	// Auth is one of AuthMethods.
+	Auth       string
+	KeyFile    string
+	Passphrase string
+	Password   string
+	// KnownHosts is the known_hosts file the host key is checked against,
+	// by default ~/.ssh/known_hosts.
+	KnownHosts string
+	// RemoteDir is the upload directory, relative to the login directory
+	// unless absolute, e.g. public_html/share.
+	RemoteDir string
+	// BaseURL is the public URL of RemoteDir, e.g.
+	// https://example.com/~me/share/.
+	BaseURL string
+}
+
+// Provider uploads files with SFTP into a directory served by a web server.
+type Provider struct {
+	config Config
+
+	// mu guards the connection, which is shared by parallel uploads.
+	mu     sync.Mutex
+	conn   *ssh.Client
+	client *sftp.Client
+}
+
+// NewProvider creates a new SFTP provider. It connects on first use.
+func NewProvider(config Config) *Provider {
+	return &Provider{config: config}
+}
+
+// address returns Host with the default port added if needed.
+func (p *Provider) address() string {
+	if _, _, err := net.SplitHostPort(p.config.Host); err == nil {
+		return p.config.Host
+	}
+	return net.JoinHostPort(p.config.Host, "22")
+}
+
+// clientConfig builds the SSH client config for the configured auth method.
+func (p *Provider) clientConfig() (*ssh.ClientConfig, error) {
+	knownHostsFile := p.config.KnownHosts
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't read known_hosts: %w", err)
+	}
+
+	var auth ssh.AuthMethod
+	switch p.config.Auth {
+	case AuthAgent, "":
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return nil, errors.New("SSH_AUTH_SOCK is not set; is ssh-agent running?")
+		}
+		auth = ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			conn, err := net.Dial("unix", sock)
+			if err != nil {
+				return nil, fmt.Errorf("can't connect to ssh-agent: %w", err)
+			}
+			// The signers use the connection, so it stays open until
+			// the process exits.
+			return agent.NewClient(conn).Signers()
+		})
+	case AuthKey:
+		key, err := os.ReadFile(p.config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read key file: %w", err)
+		}
+		var signer ssh.Signer
+		if p.config.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(p.config.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("can't parse key file: %w", err)
+		}
+		auth = ssh.PublicKeys(signer)
+	case AuthPassword:
+		auth = ssh.Password(p.config.Password)
+	default:
+		return nil, fmt.Errorf("unknown auth method: %s", p.config.Auth)
+	}
+
+	return &ssh.ClientConfig{
+		User:            p.config.User,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKeyCallback,
+	}, nil
+}
+
+// connect returns the SFTP client, dialing the server on first use.
+func (p *Provider) connect(ctx context.Context) (*sftp.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.client != nil {
+		return p.client, nil
+	}
+
+	config, err := p.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	addr := p.address()
+	var d net.Dialer
+	netConn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// Abort the handshake if ctx is cancelled.
+	stop := context.AfterFunc(ctx, func() { netConn.Close() })
+	c, chans, reqs, err := ssh.NewClientConn(netConn, addr, config)
+	if !stop() {
+		err = errors.Join(err, ctx.Err())
+	}
+	if err != nil {
+		netConn.Close()
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return nil, fmt.Errorf("host key of %s is not in known_hosts; connect once with ssh to verify it: %w", addr, err)
+		}
+		return nil, fmt.Errorf("ssh: %w", err)
+	}
+	conn := ssh.NewClient(c, chans, reqs)
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("sftp: %w", err)
+	}
+	p.conn, p.client = conn, client
+	return client, nil
+}
+
+// Close closes the connection to the server.
+func (p *Provider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.client == nil {
+		return nil
+	}
+	p.client.Close()
+	err := p.conn.Close()
+	p.conn, p.client = nil, nil
+	return err
+}
+
+// Check implements provider.Checker by logging in and looking at RemoteDir,
+// which may not exist yet since it is created on upload.
+func (p *Provider) Check(ctx context.Context) error {
+	client, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	dir := p.config.RemoteDir
+	if dir == "" {
+		return nil
+	}
+	fi, err := client.Stat(dir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("can't access %s: %w", dir, err)
+	case !fi.IsDir():
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return nil
+}
+
+// remotePath returns the path of filename on the server.
+func (p *Provider) remotePath(filename string) string {
+	dir := p.config.RemoteDir
+	if dir == "" {
+		dir = "."
+	}
+	return path.Join(dir, filename)
+}
+
+// Upload writes the content to RemoteDir/filename, creating RemoteDir if
+// needed. A partial file is removed if ctx is cancelled.
+func (p *Provider) Upload(ctx context.Context, r io.Reader, filename string, size int64) (string, error) {
+	client, err := p.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+	if dir := p.config.RemoteDir; dir != "" {
+		if err := client.MkdirAll(dir); err != nil {
+			return "", fmt.Errorf("can't create %s: %w", dir, err)
+		}
+	}
+
+	remote := p.remotePath(filename)
+	f, err := client.OpenFile(remote, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return "", fmt.Errorf("can't create %s: %w", remote, err)
+	}
+	_, err = f.ReadFrom(provider.ContextReader(ctx, r))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		client.Remove(remote)
+		return "", err
+	}
+	// Web servers usually run as another user.
+	client.Chmod(remote, 0o644)
+	return filename, nil
+}
+
+// GetLink returns BaseURL with the escaped filename appended.
+func (p *Provider) GetLink(ctx context.Context, filename string) (string, error) {
+	if p.config.BaseURL == "" {
+		return "", errors.New("no base URL configured")
+	}
+	return strings.TrimRight(p.config.BaseURL, "/") + "/" + url.PathEscape(filename), nil
+}
+
+// Delete removes the file from RemoteDir.
+func (p *Provider) Delete(ctx context.Context, filename string) error {
+	client, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	return client.Remove(p.remotePath(filename))
+}
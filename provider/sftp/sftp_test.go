@@ -0,0 +1,286 @@
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"schneider.vip/share/provider"
+)
+
+// testServer is an in-process SSH server with the SFTP subsystem serving the
+// real file system.
+type testServer struct {
+	addr       string
+	knownHosts string
+	userKeyPEM []byte
+}
+
+const testPassword = "secret"
+
+func newSigner(t *testing.T) (ssh.Signer, ed25519.PrivateKey) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, priv
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	hostKey, _ := newSigner(t)
+	userKey, userPriv := newSigner(t)
+	block, err := ssh.MarshalPrivateKey(userPriv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "me" && string(pass) == testPassword {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if c.User() == "me" && bytes.Equal(key.Marshal(), userKey.PublicKey().Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(conn, config)
+		}
+	}()
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(ln.Addr().String())}, hostKey.PublicKey())
+	if err := os.WriteFile(knownHosts, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testServer{
+		addr:       ln.Addr().String(),
+		knownHosts: knownHosts,
+		userKeyPEM: pem.EncodeToMemory(block),
+	}
+}
+
+func serveConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChan := range chans {
+		if newChan.ChannelType() != "session" {
+			newChan.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		ch, requests, err := newChan.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					server, err := sftp.NewServer(ch)
+					if err == nil {
+						server.Serve()
+					}
+					ch.Close()
+				}
+			}
+		}()
+	}
+}
+
+func (s *testServer) config(t *testing.T, auth string) Config {
+	dir := t.TempDir()
+	return Config{
+		Host:       s.addr,
+		User:       "me",
+		Auth:       auth,
+		Password:   testPassword,
+		KnownHosts: s.knownHosts,
+		RemoteDir:  filepath.Join(dir, "public_html", "share"),
+		BaseURL:    "https://example.com/~me/share/",
+	}
+}
+
+func TestUploadAuthMethods(t *testing.T) {
+	srv := newTestServer(t)
+
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyFile, srv.userKeyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// ssh-agent holding the user key.
+	keyring := agent.NewKeyring()
+	userPriv, err := ssh.ParseRawPrivateKey(srv.userKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keyring.Add(agent.AddedKey{PrivateKey: userPriv}); err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", sock)
+
+	for _, auth := range AuthMethods {
+		t.Run(auth, func(t *testing.T) {
+			config := srv.config(t, auth)
+			config.KeyFile = keyFile
+			p := NewProvider(config)
+			defer p.Close()
+			ctx := context.Background()
+
+			content := []byte("hello " + auth)
+			id, err := p.Upload(ctx, bytes.NewReader(content), "a b.txt", int64(len(content)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(filepath.Join(config.RemoteDir, "a b.txt"))
+			if err != nil || !bytes.Equal(got, content) {
+				t.Fatalf("remote file = %q, %v", got, err)
+			}
+
+			link, err := p.GetLink(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "https://example.com/~me/share/a%20b.txt"; link != want {
+				t.Errorf("link = %s, want %s", link, want)
+			}
+
+			if err := p.Delete(ctx, id); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filepath.Join(config.RemoteDir, "a b.txt")); !os.IsNotExist(err) {
+				t.Errorf("file still exists: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	srv := newTestServer(t)
+	config := srv.config(t, AuthPassword)
+	p := NewProvider(config)
+	defer p.Close()
+	if err := p.Check(context.Background()); err != nil {
+		t.Fatalf("Check before RemoteDir exists: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(config.RemoteDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config.RemoteDir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Check(context.Background()); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Errorf("err = %v, want not a directory", err)
+	}
+
+	config.Password = "wrong"
+	if err := NewProvider(config).Check(context.Background()); err == nil || !strings.Contains(err.Error(), "unable to authenticate") {
+		t.Errorf("err = %v, want authentication error", err)
+	}
+}
+
+func TestUnknownHostKey(t *testing.T) {
+	srv := newTestServer(t)
+	config := srv.config(t, AuthPassword)
+	config.KnownHosts = filepath.Join(t.TempDir(), "empty")
+	os.WriteFile(config.KnownHosts, nil, 0o600)
+
+	_, err := NewProvider(config).Upload(context.Background(), strings.NewReader("x"), "a.txt", 1)
+	if err == nil || !strings.Contains(err.Error(), "not in known_hosts") {
+		t.Errorf("err = %v, want unknown host key error", err)
+	}
+}
+
+func TestWrongPassword(t *testing.T) {
+	srv := newTestServer(t)
+	config := srv.config(t, AuthPassword)
+	config.Password = "wrong"
+
+	_, err := NewProvider(config).Upload(context.Background(), strings.NewReader("x"), "a.txt", 1)
+	if err == nil || !strings.Contains(err.Error(), "unable to authenticate") {
+		t.Errorf("err = %v, want authentication error", err)
+	}
+}
+
+func TestCancelRemovesPartialFile(t *testing.T) {
+	srv := newTestServer(t)
+	config := srv.config(t, AuthPassword)
+	p := NewProvider(config)
+	defer p.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := io.MultiReader(strings.NewReader("partial"), readerFunc(func([]byte) (int, error) {
+		cancel()
+		return 0, nil
+	}), strings.NewReader("never sent"))
+
+	_, err := p.Upload(ctx, r, "a.txt", provider.UnknownSize)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(filepath.Join(config.RemoteDir, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("partial file still exists: %v", err)
+	}
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
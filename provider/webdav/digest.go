@@ -0,0 +1,164 @@
+package webdav
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// digestChallenge is a parsed "WWW-Authenticate: Digest ..." header.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+}
+
+// parseDigestChallenge parses the parameters of a Digest challenge. ok is
+// false if header is not a Digest challenge.
+func parseDigestChallenge(header string) (c digestChallenge, ok bool) {
+	scheme, params, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(scheme, "Digest") {
+		return c, false
+	}
+	for _, kv := range splitParams(params) {
+		k, v, _ := strings.Cut(kv, "=")
+		v = strings.Trim(v, `"`)
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "realm":
+			c.realm = v
+		case "nonce":
+			c.nonce = v
+		case "opaque":
+			c.opaque = v
+		case "algorithm":
+			c.algorithm = v
+		case "qop":
+			// Prefer auth; auth-int would need the body hash.
+			for _, q := range strings.Split(v, ",") {
+				if strings.TrimSpace(q) == "auth" {
+					c.qop = "auth"
+				}
+			}
+		}
+	}
+	return c, c.nonce != ""
+}
+
+// splitParams splits comma separated auth parameters, keeping commas inside
+// quoted strings.
+func splitParams(s string) []string {
+	var parts []string
+	var b strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			b.WriteRune(r)
+		case r == ',' && !quoted:
+			parts = append(parts, strings.TrimSpace(b.String()))
+			b.Reset()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() > 0 {
+		parts = append(parts, strings.TrimSpace(b.String()))
+	}
+	return parts
+}
+
+// digestAuth computes Digest Authorization headers (RFC 7616) for a cached
+// challenge. The nonce count is shared by parallel requests.
+type digestAuth struct {
+	username, password string
+
+	mu        sync.Mutex
+	challenge *digestChallenge
+	nc        int
+}
+
+// setChallenge stores a new challenge from a 401 reply.
+func (d *digestAuth) setChallenge(c digestChallenge) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.challenge = &c
+	d.nc = 0
+}
+
+// authorize adds an Authorization header if a challenge is known.
+func (d *digestAuth) authorize(req *http.Request) error {
+	d.mu.Lock()
+	if d.challenge == nil {
+		d.mu.Unlock()
+		return nil
+	}
+	c := *d.challenge
+	d.nc++
+	nc := d.nc
+	d.mu.Unlock()
+
+	cnonce := make([]byte, 8)
+	if _, err := rand.Read(cnonce); err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", c.header(d.username, d.password, req.Method, req.URL.RequestURI(), nc, hex.EncodeToString(cnonce)))
+	return nil
+}
+
+// header builds the Authorization header value.
+func (c digestChallenge) header(username, password, method, uri string, nc int, cnonce string) string {
+	alg := strings.ToUpper(c.algorithm)
+	var h func() hash.Hash
+	switch strings.TrimSuffix(alg, "-SESS") {
+	case "SHA-256":
+		h = sha256.New
+	default:
+		h = md5.New
+	}
+	hexHash := func(s string) string {
+		sum := h()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	ha1 := hexHash(username + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(alg, "-SESS") {
+		ha1 = hexHash(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := hexHash(method + ":" + uri)
+	ncHex := fmt.Sprintf("%08x", nc)
+
+	var response string
+	if c.qop == "" {
+		response = hexHash(ha1 + ":" + c.nonce + ":" + ha2)
+	} else {
+		response = hexHash(ha1 + ":" + c.nonce + ":" + ncHex + ":" + cnonce + ":" + c.qop + ":" + ha2)
+	}
+
+	fields := []string{
+		fmt.Sprintf(`username="%s"`, username),
+		fmt.Sprintf(`realm="%s"`, c.realm),
+		fmt.Sprintf(`nonce="%s"`, c.nonce),
+		fmt.Sprintf(`uri="%s"`, uri),
+		fmt.Sprintf(`response="%s"`, response),
+	}
+	if c.algorithm != "" {
+		fields = append(fields, "algorithm="+c.algorithm)
+	}
+	if c.opaque != "" {
+		fields = append(fields, fmt.Sprintf(`opaque="%s"`, c.opaque))
+	}
+	if c.qop != "" {
+		fields = append(fields, "qop="+c.qop, "nc="+ncHex, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+	return "Digest " + strings.Join(fields, ", ")
+}
@@ -0,0 +1,301 @@
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+
+	"schneider.vip/share/provider"
+)
+
+// Authentication methods.
+const (
+	AuthNone   = "none"
+	AuthBasic  = "basic"
+	AuthDigest = "digest"
+	AuthBearer = "bearer"
+)
+
+// AuthMethods lists all authentication methods.
+var AuthMethods = []string{AuthBasic, AuthDigest, AuthBearer, AuthNone}
+
+// Config describes a WebDAV server and how uploaded files are linked.
+type Config struct {
+	// URL is the WebDAV root, e.g. https://dav.example.com/files/.
+	URL string
+	// Dir is the collection below URL uploads go to, e.g. share/2024.
+	// Missing collections are created.
+	Dir string
+	// Auth is one of AuthMethods.
+	Auth     string
+	Username string
+	Password string
+	Token    string
+	// LinkTemplate builds the public link, e.g.
+	// https://example.com/share/{{.Name}}. Fields: .Name (path-escaped
+	// filename), .RawName, .Dir (path-escaped) and .URL (the WebDAV URL of
+	// the file). If empty, the WebDAV URL is returned.
+	LinkTemplate string
+}
+
+// LinkData is passed to Config.LinkTemplate.
+type LinkData struct {
+	Name    string
+	RawName string
+	Dir     string
+	URL     string
+}
+
+// Provider uploads files to a WebDAV server.
+type Provider struct {
+	config Config
+	base   *url.URL
+	link   *template.Template
+	client *http.Client
+	digest *digestAuth
+
+	// dirMu serializes creating Dir so parallel uploads only do it once.
+	dirMu    sync.Mutex
+	dirReady bool
+}
+
+// NewProvider creates a new WebDAV provider.
+func NewProvider(config Config) (*Provider, error) {
+	base, err := url.Parse(config.URL)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("webdav: invalid URL %q", config.URL)
+	}
+	base.Path = strings.TrimRight(base.Path, "/") + "/"
+	base.RawPath = ""
+
+	var link *template.Template
+	if config.LinkTemplate != "" {
+		link, err = template.New("link").Parse(config.LinkTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("webdav: invalid link template: %w", err)
+		}
+	}
+
+	p := &Provider{config: config, base: base, link: link}
+	switch config.Auth {
+	case AuthNone, AuthBasic, AuthBearer, "":
+	case AuthDigest:
+		p.digest = &digestAuth{username: config.Username, password: config.Password}
+	default:
+		return nil, fmt.Errorf("webdav: unknown auth method: %s", config.Auth)
+	}
+	p.client = &http.Client{Transport: &authTransport{p: p, next: http.DefaultTransport}}
+	return p, nil
+}
+
+// authTransport adds credentials to every request. For Digest auth it
+// retries requests without a body once after a 401 with a new challenge;
+// uploads are preceded by such a request, so the challenge is known.
+type authTransport struct {
+	p    *Provider
+	next http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	c := t.p.config
+	switch c.Auth {
+	case AuthBasic:
+		req.SetBasicAuth(c.Username, c.Password)
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case AuthDigest:
+		if err := t.p.digest.authorize(req); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || c.Auth != AuthDigest || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge, ok := parseDigestChallenge(resp.Header.Get("WWW-Authenticate"))
+	if !ok {
+		return resp, nil
+	}
+	t.p.digest.setChallenge(challenge)
+	if req.Body != nil && req.Body != http.NoBody {
+		return resp, nil
+	}
+	resp.Body.Close()
+	retry := req.Clone(req.Context())
+	if err := t.p.digest.authorize(retry); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(retry)
+}
+
+// dirSegments returns the collections of Dir from the top.
+func (p *Provider) dirSegments() []string {
+	var segs []string
+	for _, s := range strings.Split(p.config.Dir, "/") {
+		if s != "" {
+			segs = append(segs, s)
+		}
+	}
+	return segs
+}
+
+// resolve returns the URL of the given path segments below the root.
+func (p *Provider) resolve(segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, s := range segments {
+		escaped[i] = url.PathEscape(s)
+	}
+	u := *p.base
+	u.RawPath = p.base.EscapedPath() + strings.Join(escaped, "/")
+	u.Path, _ = url.PathUnescape(u.RawPath)
+	return u.String()
+}
+
+// fileURL returns the WebDAV URL of filename.
+func (p *Provider) fileURL(filename string) string {
+	return p.resolve(append(p.dirSegments(), filename)...)
+}
+
+// do sends a request without body and returns an error for error statuses
+// other than the accepted ones.
+func (p *Provider) do(ctx context.Context, method, u string, accept ...int) error {
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	for _, code := range accept {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	return statusError(method, u, resp)
+}
+
+func statusError(method, u string, resp *http.Response) error {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("%s %s failed (%d): %s", method, u, resp.StatusCode, bytes.TrimSpace(b))
+}
+
+// ensureDir creates the collections of Dir one by one, since MKCOL does not
+// create parents. 405 means the collection exists. Without Dir an OPTIONS
+// request is sent instead, so a Digest challenge is known before the upload.
+func (p *Provider) ensureDir(ctx context.Context) error {
+	p.dirMu.Lock()
+	defer p.dirMu.Unlock()
+	if p.dirReady {
+		return nil
+	}
+
+	segs := p.dirSegments()
+	if len(segs) == 0 {
+		if err := p.do(ctx, "OPTIONS", p.base.String()); err != nil {
+			return err
+		}
+	}
+	for i := range segs {
+		if err := p.do(ctx, "MKCOL", p.resolve(segs[:i+1]...)+"/", http.StatusMethodNotAllowed); err != nil {
+			return fmt.Errorf("can't create collection: %w", err)
+		}
+	}
+	p.dirReady = true
+	return nil
+}
+
+// Check implements provider.Checker by reading the properties of the root
+// URL with the configured credentials. Dir is created on upload, so it may
+// not exist yet.
+func (p *Provider) Check(ctx context.Context) error {
+	u := p.base.String()
+	req, err := http.NewRequestWithContext(ctx, "PROPFIND", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Depth", "0")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return statusError("PROPFIND", u, resp)
+	}
+	return nil
+}
+
+// Upload PUTs the file into Dir. A partial file is deleted if ctx is
+// cancelled.
+func (p *Provider) Upload(ctx context.Context, r io.Reader, filename string, size int64) (string, error) {
+	if err := p.ensureDir(ctx); err != nil {
+		return "", err
+	}
+
+	u := p.fileURL(filename)
+	req, err := http.NewRequestWithContext(ctx, "PUT", u, r)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			cleanupCtx, cancel := provider.CleanupContext(ctx)
+			defer cancel()
+			p.do(cleanupCtx, "DELETE", u, http.StatusNotFound)
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", statusError("PUT", u, resp)
+	}
+	return filename, nil
+}
+
+// GetLink executes LinkTemplate for the file, or returns its WebDAV URL.
+func (p *Provider) GetLink(ctx context.Context, filename string) (string, error) {
+	segs := p.dirSegments()
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	data := LinkData{
+		Name:    url.PathEscape(filename),
+		RawName: filename,
+		Dir:     strings.Join(segs, "/"),
+		URL:     p.fileURL(filename),
+	}
+	if p.link == nil {
+		return data.URL, nil
+	}
+	var b strings.Builder
+	if err := p.link.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("link template: %w", err)
+	}
+	if b.Len() == 0 {
+		return "", errors.New("link template produced an empty link")
+	}
+	return b.String(), nil
+}
+
+// Delete removes the file.
+func (p *Provider) Delete(ctx context.Context, filename string) error {
+	return p.do(ctx, "DELETE", p.fileURL(filename))
+}
@@ -0,0 +1,212 @@
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+func TestDigestRFC2617Example(t *testing.T) {
+	c := digestChallenge{
+		realm: "testrealm@host.com",
+		nonce: "dcd98b7102dd2f0e8b11d0f600bfb0c093",
+		qop:   "auth",
+	}
+	got := c.header("Mufasa", "Circle Of Life", "GET", "/dir/index.html", 1, "0a4f113b")
+	if !strings.Contains(got, `response="6629fae49393a05397450978507c4ef1"`) {
+		t.Errorf("header = %s", got)
+	}
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	c, ok := parseDigestChallenge(`Digest realm="a, b", qop="auth,auth-int", nonce="n1", opaque="o"`)
+	if !ok || c.realm != "a, b" || c.qop != "auth" || c.nonce != "n1" || c.opaque != "o" {
+		t.Errorf("parseDigestChallenge = %+v, %v", c, ok)
+	}
+	if _, ok := parseDigestChallenge(`Basic realm="x"`); ok {
+		t.Error("Basic challenge parsed as Digest")
+	}
+}
+
+const (
+	testUser  = "me"
+	testPass  = "secret"
+	testToken = "tok"
+	testRealm = "dav"
+	testNonce = "abc123"
+)
+
+// authHandler protects the in-process WebDAV server with the given method.
+func authHandler(method string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok := false
+		switch method {
+		case AuthBasic:
+			u, p, _ := r.BasicAuth()
+			ok = u == testUser && p == testPass
+		case AuthBearer:
+			ok = r.Header.Get("Authorization") == "Bearer "+testToken
+		case AuthDigest:
+			ok = checkDigest(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Digest realm="`+testRealm+`", qop="auth", nonce="`+testNonce+`", algorithm=MD5`)
+			}
+		case AuthNone:
+			ok = true
+		}
+		if !ok {
+			// Like real servers, read the body before answering.
+			io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkDigest verifies a Digest response computed independently of the
+// client code.
+func checkDigest(r *http.Request) bool {
+	params := map[string]string{}
+	scheme, rest, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if scheme != "Digest" {
+		return false
+	}
+	for _, kv := range splitParams(rest) {
+		k, v, _ := strings.Cut(kv, "=")
+		params[k] = strings.Trim(v, `"`)
+	}
+	md5hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	ha1 := md5hex(testUser + ":" + testRealm + ":" + testPass)
+	ha2 := md5hex(r.Method + ":" + r.URL.RequestURI())
+	want := md5hex(ha1 + ":" + testNonce + ":" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+	return params["username"] == testUser && params["uri"] == r.URL.RequestURI() && params["response"] == want
+}
+
+func newTestServer(t *testing.T, auth string) (*httptest.Server, webdav.FileSystem) {
+	fs := webdav.NewMemFS()
+	h := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: fs,
+		LockSystem: webdav.NewMemLS(),
+	}
+	srv := httptest.NewServer(authHandler(auth, h))
+	t.Cleanup(srv.Close)
+	return srv, fs
+}
+
+func readFile(t *testing.T, fs webdav.FileSystem, name string) []byte {
+	t.Helper()
+	f, err := fs.OpenFile(context.Background(), name, os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("open %s: %v", name, err)
+	}
+	defer f.Close()
+	b, _ := io.ReadAll(f)
+	return b
+}
+
+func TestUploadAuthMethods(t *testing.T) {
+	for _, auth := range AuthMethods {
+		t.Run(auth, func(t *testing.T) {
+			srv, fs := newTestServer(t, auth)
+			p, err := NewProvider(Config{
+				URL:          srv.URL + "/dav",
+				Dir:          "/share/2024 q1/",
+				Auth:         auth,
+				Username:     testUser,
+				Password:     testPass,
+				Token:        testToken,
+				LinkTemplate: "https://example.com/{{.Dir}}/{{.Name}}",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+
+			for _, name := range []string{"a.txt", "b c#1.txt"} {
+				content := []byte("hello " + name)
+				id, err := p.Upload(ctx, bytes.NewReader(content), name, int64(len(content)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := readFile(t, fs, "/share/2024 q1/"+name); !bytes.Equal(got, content) {
+					t.Errorf("stored %q, want %q", got, content)
+				}
+				link, err := p.GetLink(ctx, id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := "https://example.com/share/2024%20q1/" + strings.ReplaceAll(strings.ReplaceAll(name, " ", "%20"), "#", "%23"); link != want {
+					t.Errorf("link = %s, want %s", link, want)
+				}
+			}
+
+			if err := p.Delete(ctx, "a.txt"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fs.Stat(ctx, "/share/2024 q1/a.txt"); !os.IsNotExist(err) {
+				t.Errorf("file still exists: %v", err)
+			}
+		})
+	}
+}
+
+func TestWrongCredentials(t *testing.T) {
+	for _, auth := range []string{AuthBasic, AuthDigest} {
+		srv, _ := newTestServer(t, auth)
+		p, err := NewProvider(Config{URL: srv.URL + "/dav", Auth: auth, Username: testUser, Password: "wrong"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = p.Upload(context.Background(), strings.NewReader("x"), "a.txt", 1)
+		if err == nil || !strings.Contains(err.Error(), "401") {
+			t.Errorf("%s: err = %v, want 401", auth, err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, auth := range AuthMethods {
+		srv, _ := newTestServer(t, auth)
+		p, err := NewProvider(Config{URL: srv.URL + "/dav", Dir: "share", Auth: auth, Username: testUser, Password: testPass, Token: testToken})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Check(context.Background()); err != nil {
+			t.Errorf("%s: Check: %v", auth, err)
+		}
+	}
+
+	srv, _ := newTestServer(t, AuthDigest)
+	p, err := NewProvider(Config{URL: srv.URL + "/dav", Auth: AuthDigest, Username: testUser, Password: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Check(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("err = %v, want 401", err)
+	}
+}
+
+func TestDefaultLink(t *testing.T) {
+	p, err := NewProvider(Config{URL: "https://dav.example.com/files", Dir: "share"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, _ := p.GetLink(context.Background(), "a b.txt")
+	if want := "https://dav.example.com/files/share/a%20b.txt"; link != want {
+		t.Errorf("link = %s, want %s", link, want)
+	}
+}
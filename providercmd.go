@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"schneider.vip/share/config"
+	"schneider.vip/share/tui"
+	"schneider.vip/share/tui/setup"
+)
+
+// ProviderCmd groups the commands managing providers without the setup forms.
+type ProviderCmd struct {
+	List   ProviderListCmd   `cmd:"" default:"withargs" help:"List the configured providers (default)."`
+	Add    ProviderAddCmd    `cmd:"" help:"Add a provider."`
+	Rm     ProviderRmCmd     `cmd:"" help:"Remove a provider and its stored secrets."`
+	Use    ProviderUseCmd    `cmd:"" help:"Set the active provider."`
+	Rename ProviderRenameCmd `cmd:"" help:"Change the label of a provider."`
+}
+
+// ProviderListCmd lists the configured providers.
+type ProviderListCmd struct {
+	Output string `help:"Output format: text or json (one object per provider, without secrets)." enum:"text,json" default:"text" short:"o"`
+}
+
+// ProviderAddCmd adds a provider from flags and SHARECMD_* variables.
+type ProviderAddCmd struct {
+	Type  string   `help:"Provider type: ${providerTypes}." required:"" placeholder:"TYPE"`
+	Label string   `help:"Unique label of the provider (default: $SHARECMD_PROVIDER_LABEL or the type)."`
+	Set   []string `help:"Provider setting; repeatable. Settings are also read from SHARECMD_* variables." placeholder:"KEY=VALUE" sep:"none"`
+	Use   bool     `help:"Make it the active provider (default for the first provider)."`
+}
+
+// ProviderRmCmd removes a provider.
+type ProviderRmCmd struct {
+	Label string `arg:"" help:"Label of the provider."`
+}
+
+// ProviderUseCmd sets the active provider.
+type ProviderUseCmd struct {
+	Label string `arg:"" help:"Label of the provider."`
+}
+
+// ProviderRenameCmd changes the label of a provider.
+type ProviderRenameCmd struct {
+	Label    string `arg:"" help:"Current label of the provider."`
+	NewLabel string `arg:"" help:"New label of the provider."`
+}
+
+// jsonProvider is the --output json line for one provider. Settings holding
+// secrets are left out.
+type jsonProvider struct {
+	Label    string            `json:"label"`
+	Type     string            `json:"type"`
+	Active   bool              `json:"active"`
+	Settings map[string]string `json:"settings"`
+}
+
+// Run prints the providers, marking the active one.
+func (c *ProviderListCmd) Run(g *Globals) error {
+	cfg, err := config.LookupConfig(g.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if c.Output == outputJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, p := range cfg.Providers {
+			settings := maps.Clone(p.Settings)
+			maps.DeleteFunc(settings, func(key, _ string) bool {
+				return slices.Contains(config.SecretKeys, key)
+			})
+			if settings == nil {
+				settings = map[string]string{}
+			}
+			enc.Encode(jsonProvider{Label: p.Label, Type: p.Type, Active: p.Label == cfg.Active, Settings: settings})
+		}
+		return nil
+	}
+
+	if len(cfg.Providers) == 0 {
+		fmt.Println(tui.Subtle.Render("No providers configured."))
+		return nil
+	}
+	width := 0
+	for _, p := range cfg.Providers {
+		width = max(width, len(p.Label))
+	}
+	for _, p := range cfg.Providers {
+		mark := "  "
+		if p.Label == cfg.Active {
+			mark = tui.Success.Render("*") + " "
+		}
+		fmt.Printf("%s%-*s  %s\n", mark, width, p.Label, tui.Subtle.Render(p.Type))
+	}
+	return nil
+}
+
+// Run adds the provider to the config.
+func (c *ProviderAddCmd) Run(g *Globals) error {
+	entry, err := config.AdHocProvider(c.Type, os.Environ(), setup.SettingKeys)
+	if err != nil {
+		return err
+	}
+	if err := entry.Override(c.Set, setup.SettingKeys[entry.Type]); err != nil {
+		return err
+	}
+	if c.Label != "" {
+		entry.Label = c.Label
+	}
+
+	err = updateConfig(g, func(cfg *config.Config) error {
+		if err := cfg.AddProvider(*entry); err != nil {
+			return err
+		}
+		if c.Use || len(cfg.Providers) == 1 {
+			cfg.Active = entry.Label
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(tui.Success.Render(fmt.Sprintf("Provider %q added.", entry.Label)))
+	return nil
+}
+
+// Run removes the provider from the config.
+func (c *ProviderRmCmd) Run(g *Globals) error {
+	err := updateConfig(g, func(cfg *config.Config) error {
+		if cfg.FindByLabel(c.Label) == nil {
+			return fmt.Errorf("provider %q not found", c.Label)
+		}
+		cfg.RemoveProvider(c.Label)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(tui.Success.Render(fmt.Sprintf("Provider %q deleted.", c.Label)))
+	return nil
+}
+
+// Run sets the active provider.
+func (c *ProviderUseCmd) Run(g *Globals) error {
+	err := updateConfig(g, func(cfg *config.Config) error {
+		return cfg.SetActive(c.Label)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(tui.Success.Render(fmt.Sprintf("Active provider set to %q", c.Label)))
+	return nil
+}
+
+// Run renames the provider.
+func (c *ProviderRenameCmd) Run(g *Globals) error {
+	err := updateConfig(g, func(cfg *config.Config) error {
+		return cfg.RenameProvider(c.Label, c.NewLabel)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(tui.Success.Render(fmt.Sprintf("Provider %q renamed to %q.", c.Label, c.NewLabel)))
+	return nil
+}
+
+// updateConfig loads the config and applies fn to it with config.Update.
+func updateConfig(g *Globals, fn func(*config.Config) error) error {
+	cfg, err := config.LookupConfig(g.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	return cfg.Update(fn)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"schneider.vip/share/config"
+	"schneider.vip/share/history"
+	"schneider.vip/share/provider"
+	"schneider.vip/share/tui"
+	"schneider.vip/share/tui/setup"
+)
+
+// RmCmd deletes an uploaded file or revokes its public link.
+type RmCmd struct {
+	Revoke   bool   `help:"Only revoke the public link and keep the file."`
+	Provider string `help:"Provider label, for file IDs that are not in the upload history." short:"p"`
+	Target   string `arg:"" help:"Link or remote file ID of the upload."`
+}
+
+// Run looks up the upload in the history and removes it with its provider.
+func (c *RmCmd) Run(g *Globals) error {
+	cfg, err := config.LookupConfig(g.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	store := history.Open(history.DefaultPath(g.ConfigPath))
+	entries, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	// The history has links without the key of encrypted uploads.
+	target := withoutKey(c.Target)
+	label, fileID := c.Provider, c.Target
+	adhoc := false
+	idx := history.Find(entries, target)
+	if idx >= 0 {
+		label, fileID, adhoc = entries[idx].Provider, entries[idx].FileID, entries[idx].AdHoc
+	} else if label == "" {
+		return fmt.Errorf("%q not found in upload history; pass --provider to remove a file ID directly", c.Target)
+	}
+
+	var entry *config.ProviderEntry
+	var prov provider.Provider
+	if adhoc {
+		// The provider is not in the config; take it from the environment
+		// again, like the upload did.
+		typ := entries[idx].Type
+		if os.Getenv(config.EnvProviderType) != typ {
+			return fmt.Errorf("%q was uploaded with a %s provider from the environment; set %s=%s and its settings to remove it", c.Target, typ, config.EnvProviderType, typ)
+		}
+		entry, err = config.AdHocProvider("", os.Environ(), setup.SettingKeys)
+		if err != nil {
+			return err
+		}
+		prov, err = instantiateProvider(entry)
+	} else {
+		entry = cfg.FindByLabel(label)
+		if entry == nil {
+			return fmt.Errorf("provider %q not found", label)
+		}
+		prov, err = openProvider(cfg, entry)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create provider: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	removed := history.Deleted
+	if c.Revoke {
+		revoker, ok := prov.(provider.Revoker)
+		if !ok {
+			return fmt.Errorf("provider type %s cannot revoke links; run without --revoke to delete the file", entry.Type)
+		}
+		if err := revoker.Revoke(ctx, fileID); err != nil {
+			return fmt.Errorf("revoke failed: %w", err)
+		}
+		removed = history.Revoked
+	} else {
+		deleter, ok := prov.(provider.Deleter)
+		if !ok {
+			return fmt.Errorf("provider type %s cannot delete files", entry.Type)
+		}
+		if err := deleter.Delete(ctx, fileID); err != nil {
+			return fmt.Errorf("delete failed: %w", err)
+		}
+	}
+
+	if idx >= 0 {
+		// Find the entry again, the history may have changed meanwhile.
+		err := store.Update(func(entries []history.Entry) ([]history.Entry, error) {
+			if i := history.Find(entries, target); i >= 0 {
+				entries[i].Removed = removed
+			}
+			return entries, nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update upload history: %w", err)
+		}
+	}
+
+	if c.Revoke {
+		fmt.Println(tui.Success.Render(fmt.Sprintf("Link for %s revoked on %q.", fileID, label)))
+	} else {
+		fmt.Println(tui.Success.Render(fmt.Sprintf("%s deleted from %q.", fileID, label)))
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package secret
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ExecStore runs a helper command for every operation, in the style of git
+// credential helpers:
+//
+//	<command> get <id>     prints the secret; no output if it does not exist
+//	<command> store <id>   reads the secret from stdin
+//	<command> erase <id>   removes the secret
+//
+// A small script maps these to pass, op or any other password manager.
+type ExecStore struct {
+	command []string
+}
+
+// NewExecStore creates a store using command, which is split at spaces.
+func NewExecStore(command string) (*ExecStore, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("secret helper command is empty")
+	}
+	return &ExecStore{command: args}, nil
+}
+
+func (s *ExecStore) run(stdin string, args ...string) (string, error) {
+	args = append(s.command[1:len(s.command):len(s.command)], args...)
+	cmd := exec.Command(s.command[0], args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stderr = os.Stderr
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("secret helper %s %s: %w", s.command[0], args[len(args)-2], err)
+	}
+	return out.String(), nil
+}
+
+// Get runs "<command> get <id>". A trailing newline is removed.
+func (s *ExecStore) Get(id string) (string, error) {
+	out, err := s.run("", "get", id)
+	if err != nil {
+		return "", err
+	}
+	out = strings.TrimSuffix(strings.TrimSuffix(out, "\n"), "\r")
+	if out == "" {
+		return "", ErrNotFound
+	}
+	return out, nil
+}
+
+// Set runs "<command> store <id>" with the secret on stdin.
+func (s *ExecStore) Set(id, value string) error {
+	_, err := s.run(value, "store", id)
+	return err
+}
+
+// Delete runs "<command> erase <id>".
+func (s *ExecStore) Delete(id string) error {
+	_, err := s.run("", "erase", id)
+	return err
+}
@@ -0,0 +1,248 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// PassphraseEnv is the environment variable PromptPassphrase reads the
+// passphrase from before asking on the terminal.
+const PassphraseEnv = "SHARECMD_SECRET_PASSPHRASE"
+
+// ErrPassphrase is returned when the secrets file cannot be decrypted.
+var ErrPassphrase = errors.New("wrong passphrase or corrupted secrets file")
+
+// scrypt parameters for new files; existing files keep theirs.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// fileFormat is the JSON stored on disk. Data is the JSON object of all
+// secrets, sealed with AES-256-GCM under the scrypt key of the passphrase.
+type fileFormat struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// FileStore keeps all secrets in one file encrypted with a passphrase. The
+// file is read again on every access, so that changes made by other
+// processes are seen; only the passphrase and the key derived from it are
+// kept. Writers in several processes must still be serialized, as Config
+// does with its lock, since Set and Delete read, change and replace the
+// whole file.
+type FileStore struct {
+	path       string
+	passphrase func(create bool) (string, error)
+
+	mu      sync.Mutex
+	secret  string
+	header  fileFormat
+	aead    cipher.AEAD
+	secrets map[string]string
+}
+
+// NewFileStore creates a store for the file at path. passphrase is called
+// once, with create set if the file does not exist yet.
+func NewFileStore(path string, passphrase func(create bool) (string, error)) *FileStore {
+	return &FileStore{path: path, passphrase: passphrase}
+}
+
+func newAEAD(passphrase string, h fileFormat) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), h.Salt, h.N, h.R, h.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sameKey reports whether a and b derive the same key from a passphrase.
+func sameKey(a, b fileFormat) bool {
+	return bytes.Equal(a.Salt, b.Salt) && a.N == b.N && a.R == b.R && a.P == b.P
+}
+
+// load decrypts the current file, or prepares a new one.
+func (s *FileStore) load() error {
+	content, err := os.ReadFile(s.path)
+	create := os.IsNotExist(err)
+	if err != nil && !create {
+		return err
+	}
+	var h fileFormat
+	if create {
+		if s.aead != nil {
+			// The file was removed; write a new one with the same key.
+			s.secrets = map[string]string{}
+			return nil
+		}
+		h = fileFormat{Version: 1, Salt: make([]byte, 16), N: scryptN, R: scryptR, P: scryptP}
+		if _, err := rand.Read(h.Salt); err != nil {
+			return err
+		}
+	} else if err := json.Unmarshal(content, &h); err != nil {
+		return fmt.Errorf("invalid secrets file %s: %w", s.path, err)
+	}
+
+	if s.aead == nil || !sameKey(h, s.header) {
+		if s.aead == nil {
+			if s.secret, err = s.passphrase(create); err != nil {
+				return err
+			}
+		}
+		aead, err := newAEAD(s.secret, h)
+		if err != nil {
+			return err
+		}
+		s.header, s.aead = h, aead
+	}
+	secrets := map[string]string{}
+	if !create {
+		plain, err := s.aead.Open(nil, h.Nonce, h.Data, nil)
+		if err != nil {
+			// Ask again next time.
+			s.aead = nil
+			return ErrPassphrase
+		}
+		if err := json.Unmarshal(plain, &secrets); err != nil {
+			return fmt.Errorf("invalid secrets file %s: %w", s.path, err)
+		}
+	}
+	s.secrets = secrets
+	return nil
+}
+
+// save encrypts all secrets with a new nonce and replaces the file.
+func (s *FileStore) save() error {
+	plain, err := json.Marshal(s.secrets)
+	if err != nil {
+		return err
+	}
+	h := s.header
+	h.Nonce = make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(h.Nonce); err != nil {
+		return err
+	}
+	h.Data = s.aead.Seal(nil, h.Nonce, plain, nil)
+	content, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Get returns the secret from the file.
+func (s *FileStore) Get(id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return "", err
+	}
+	value, ok := s.secrets[id]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+// Set stores the secret and rewrites the file.
+func (s *FileStore) Set(id, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	s.secrets[id] = value
+	return s.save()
+}
+
+// Delete removes the secret and rewrites the file.
+func (s *FileStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	if _, ok := s.secrets[id]; !ok {
+		return nil
+	}
+	delete(s.secrets, id)
+	return s.save()
+}
+
+// PromptPassphrase returns the passphrase from PassphraseEnv, or asks for it
+// on the terminal; a new passphrase has to be entered twice.
+func PromptPassphrase(create bool) (string, error) {
+	if p := os.Getenv(PassphraseEnv); p != "" {
+		return p, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no terminal to ask for the passphrase of the secrets file; set %s", PassphraseEnv)
+	}
+	prompt := "Passphrase for sharecmd secrets: "
+	if create {
+		prompt = "New passphrase for sharecmd secrets: "
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	p, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if len(p) == 0 {
+		return "", errors.New("empty passphrase")
+	}
+	if create {
+		fmt.Fprint(os.Stderr, "Repeat passphrase: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(again) != string(p) {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return string(p), nil
+}
@@ -0,0 +1,38 @@
+package secret
+
+import (
+	"errors"
+
+	"github.com/zalando/go-keyring"
+)
+
+// keyringService is the service name of all sharecmd entries in the keyring.
+const keyringService = "sharecmd"
+
+// KeyringStore keeps secrets in the system keyring. On Linux it talks to the
+// Secret Service over D-Bus, which is what libsecret, GNOME Keyring and
+// KWallet implement.
+type KeyringStore struct{}
+
+// Get returns the secret from the keyring.
+func (KeyringStore) Get(id string) (string, error) {
+	value, err := keyring.Get(keyringService, id)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+// Set stores the secret in the keyring.
+func (KeyringStore) Set(id, value string) error {
+	return keyring.Set(keyringService, id, value)
+}
+
+// Delete removes the secret from the keyring.
+func (KeyringStore) Delete(id string) error {
+	err := keyring.Delete(keyringService, id)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil
+	}
+	return err
+}
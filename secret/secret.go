@@ -0,0 +1,82 @@
+// Package secret keeps credentials out of the config file. The config only
+// holds references like "secret:3f9c0a1b2d4e5f60"; the secrets themselves are
+// kept in the system keyring (the Secret Service used by libsecret on Linux,
+// the Keychain on macOS, the Credential Manager on Windows), in a file
+// encrypted with a passphrase, or by an external helper command.
+package secret
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Store saves secrets under an ID.
+type Store interface {
+	// Get returns the secret, or ErrNotFound.
+	Get(id string) (string, error)
+	// Set creates or replaces the secret.
+	Set(id, value string) error
+	// Delete removes the secret; deleting a missing secret is not an error.
+	Delete(id string) error
+}
+
+// ErrNotFound is returned by Store.Get for unknown IDs.
+var ErrNotFound = errors.New("secret not found")
+
+// Store specs understood by Open.
+const (
+	// Keyring is the system keyring.
+	Keyring = "keyring"
+	// File is an encrypted file next to the config; "file:<path>" names
+	// another file.
+	File = "file"
+	// ExecPrefix starts the command of a helper, e.g. "exec:sharecmd-pass".
+	ExecPrefix = "exec:"
+)
+
+// FileName is the name of the encrypted file in the config directory.
+const FileName = "secrets.enc"
+
+// Open returns the store described by spec. dir is the config directory,
+// where the encrypted file is kept by default.
+func Open(spec, dir string) (Store, error) {
+	switch {
+	case spec == Keyring:
+		return KeyringStore{}, nil
+	case spec == File:
+		return NewFileStore(filepath.Join(dir, FileName), PromptPassphrase), nil
+	case strings.HasPrefix(spec, File+":"):
+		return NewFileStore(strings.TrimPrefix(spec, File+":"), PromptPassphrase), nil
+	case strings.HasPrefix(spec, ExecPrefix):
+		return NewExecStore(strings.TrimPrefix(spec, ExecPrefix))
+	default:
+		return nil, fmt.Errorf("unknown secret store %q, expecting %s, %s, %s:<path> or %s<command>", spec, Keyring, File, File, ExecPrefix)
+	}
+}
+
+// refPrefix starts a reference to a secret in a setting.
+const refPrefix = "secret:"
+
+// Ref returns the reference to the secret with the given ID.
+func Ref(id string) string {
+	return refPrefix + id
+}
+
+// ParseRef returns the ID of a reference, or false if value is not one.
+func ParseRef(value string) (string, bool) {
+	id, ok := strings.CutPrefix(value, refPrefix)
+	return id, ok && id != ""
+}
+
+// NewID returns a random ID for a new secret.
+func NewID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
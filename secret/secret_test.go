@@ -0,0 +1,191 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+// testStore runs the Store contract against s.
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	if _, err := s.Get("a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of missing secret: err = %v", err)
+	}
+	if err := s.Set("a", "one"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("b", `{"access_token":"x"}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("a", "two"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := s.Get("a"); err != nil || v != "two" {
+		t.Fatalf("Get(a) = %q, %v", v, err)
+	}
+	if err := s.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("a"); err != nil {
+		t.Fatalf("Delete of missing secret: %v", err)
+	}
+	if _, err := s.Get("a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete: err = %v", err)
+	}
+	if v, err := s.Get("b"); err != nil || v != `{"access_token":"x"}` {
+		t.Fatalf("Get(b) = %q, %v", v, err)
+	}
+}
+
+func TestRef(t *testing.T) {
+	id, err := NewID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := ParseRef(Ref(id)); !ok || got != id {
+		t.Errorf("ParseRef(Ref(%s)) = %s, %v", id, got, ok)
+	}
+	for _, v := range []string{"", "hunter2", "secret:"} {
+		if _, ok := ParseRef(v); ok {
+			t.Errorf("%q parsed as reference", v)
+		}
+	}
+}
+
+func TestKeyringStore(t *testing.T) {
+	keyring.MockInit()
+	testStore(t, KeyringStore{})
+}
+
+func passphrase(p string, calls *int) func(bool) (string, error) {
+	return func(bool) (string, error) {
+		*calls++
+		return p, nil
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", FileName)
+	var calls int
+	testStore(t, NewFileStore(path, passphrase("correct horse", &calls)))
+	if calls != 1 {
+		t.Errorf("passphrase asked %d times, want once", calls)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "access_token") {
+		t.Error("secret stored in plaintext")
+	}
+	if fi, _ := os.Stat(path); runtime.GOOS != "windows" && fi.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v", fi.Mode().Perm())
+	}
+
+	// A new store reads what the first one wrote.
+	s := NewFileStore(path, passphrase("correct horse", &calls))
+	if v, err := s.Get("b"); err != nil || v != `{"access_token":"x"}` {
+		t.Errorf("reopened Get(b) = %q, %v", v, err)
+	}
+
+	s = NewFileStore(path, passphrase("wrong", &calls))
+	if _, err := s.Get("b"); !errors.Is(err, ErrPassphrase) {
+		t.Errorf("wrong passphrase: err = %v", err)
+	}
+}
+
+func TestFileStoreShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	var calls int
+	a := NewFileStore(path, passphrase("correct horse", &calls))
+	b := NewFileStore(path, passphrase("correct horse", &calls))
+	// Like two processes, each store writes over what the other wrote.
+	if err := a.Set("a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Set("new", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Set("a", "3"); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []*FileStore{a, b} {
+		if v, err := s.Get("new"); err != nil || v != "2" {
+			t.Errorf("Get(new) = %q, %v", v, err)
+		}
+		if v, err := s.Get("a"); err != nil || v != "3" {
+			t.Errorf("Get(a) = %q, %v", v, err)
+		}
+	}
+	if err := b.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Get("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(a) after Delete: err = %v", err)
+	}
+}
+
+func TestExecStore(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("helper is a shell script")
+	}
+	dir := t.TempDir()
+	helper := filepath.Join(dir, "helper")
+	script := `#!/bin/sh
+f="` + dir + `/$2"
+case "$1" in
+get) [ -f "$f" ] && cat "$f" && echo ;;
+store) cat > "$f" ;;
+erase) rm -f "$f" ;;
+*) exit 2 ;;
+esac
+exit 0
+`
+	if err := os.WriteFile(helper, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewExecStore(helper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+
+	s, _ = NewExecStore("false")
+	if _, err := s.Get("a"); err == nil || !strings.Contains(err.Error(), "secret helper false get") {
+		t.Errorf("failing helper: err = %v", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	for spec, want := range map[string]string{
+		Keyring:               "secret.KeyringStore",
+		File:                  "*secret.FileStore",
+		"file:/tmp/x.enc":     "*secret.FileStore",
+		"exec:pass-helper -q": "*secret.ExecStore",
+	} {
+		s, err := Open(spec, dir)
+		if err != nil {
+			t.Fatalf("Open(%s): %v", spec, err)
+		}
+		if got := fmt.Sprintf("%T", s); got != want {
+			t.Errorf("Open(%s) = %s, want %s", spec, got, want)
+		}
+	}
+	if fs, _ := Open(File, dir); fs.(*FileStore).path != filepath.Join(dir, FileName) {
+		t.Errorf("file store path = %s", fs.(*FileStore).path)
+	}
+	for _, spec := range []string{"", "vault", "exec:"} {
+		if _, err := Open(spec, dir); err == nil {
+			t.Errorf("Open(%q) succeeded", spec)
+		}
+	}
+}
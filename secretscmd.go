@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+
+	"schneider.vip/share/config"
+	"schneider.vip/share/tui"
+)
+
+// storeConfigFile is the migrate argument for keeping secrets in the config
+// file.
+const storeConfigFile = "config"
+
+// SecretsCmd groups the commands working on the secret store.
+type SecretsCmd struct {
+	Migrate SecretsMigrateCmd `cmd:"" help:"Move the secrets of all providers to another store."`
+}
+
+// SecretsMigrateCmd moves secrets between stores.
+type SecretsMigrateCmd struct {
+	Store string `arg:"" help:"keyring, file, file:PATH, exec:COMMAND, or config to keep them in the config file."`
+}
+
+// Run moves all secrets to the new store and saves the config.
+func (c *SecretsMigrateCmd) Run(g *Globals) error {
+	cfg, err := config.LookupConfig(g.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	spec := c.Store
+	if spec == storeConfigFile {
+		spec = ""
+	}
+	if err := cfg.MigrateSecrets(spec); err != nil {
+		return err
+	}
+	fmt.Println(tui.Success.Render(fmt.Sprintf("Secrets moved to %s.", c.Store)))
+	return nil
+}
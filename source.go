@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"schneider.vip/share/archive"
+	"schneider.vip/share/crypt"
+	"schneider.vip/share/provider"
+)
+
+// stdinArg is the file argument that selects reading from stdin.
+const stdinArg = "-"
+
+// source is something that can be uploaded: a regular file, or a directory
+// that is streamed as an archive.
+type source struct {
+	name string
+	size int64
+	// estimate is size if known, otherwise the expected size or
+	// provider.UnknownSize. It is only used to show progress.
+	estimate int64
+	open     func() (io.ReadCloser, error)
+	// key is set if the content is encrypted before upload.
+	key *crypt.Key
+}
+
+// newSource prepares the file or directory at path for upload. Directories
+// are archived in archiveFormat while uploading; the archive size is only
+// known afterwards, so the size of the files in it is the estimate.
+func newSource(path, archiveFormat string) (*source, error) {
+	if path == stdinArg {
+		return nil, errors.New("stdin must be opened with stdinSource")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't stat file: %w", err)
+	}
+
+	if info.IsDir() {
+		arc, err := archive.New(path, archiveFormat)
+		if err != nil {
+			return nil, err
+		}
+		estimate, err := arc.ContentSize()
+		if err != nil {
+			return nil, fmt.Errorf("can't read directory: %w", err)
+		}
+		return &source{
+			name:     arc.Name(),
+			size:     provider.UnknownSize,
+			estimate: estimate,
+			open:     func() (io.ReadCloser, error) { return arc.Open(), nil },
+		}, nil
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("not a regular file or directory: %s", path)
+	}
+	return &source{
+		name:     filepath.Base(path),
+		size:     info.Size(),
+		estimate: info.Size(),
+		open:     func() (io.ReadCloser, error) { return os.Open(path) },
+	}, nil
+}
+
+// stdinSource uploads whatever is piped into the process as name. The size is
+// unknown and stdin can only be read once.
+func stdinSource(name string) *source {
+	var opened atomic.Bool
+	return &source{
+		name:     name,
+		size:     provider.UnknownSize,
+		estimate: provider.UnknownSize,
+		open: func() (io.ReadCloser, error) {
+			if opened.Swap(true) {
+				return nil, errors.New("stdin can only be uploaded once")
+			}
+			return io.NopCloser(os.Stdin), nil
+		},
+	}
+}
+
+// encryptedSource wraps src so that its content is encrypted with a new
+// random key while it is uploaded.
+func encryptedSource(src *source) (*source, error) {
+	key, err := crypt.NewKey()
+	if err != nil {
+		return nil, fmt.Errorf("can't generate key: %w", err)
+	}
+	return &source{
+		name:     src.name + crypt.Ext,
+		size:     crypt.EncryptedSize(src.size),
+		estimate: crypt.EncryptedSize(src.estimate),
+		open: func() (io.ReadCloser, error) {
+			rc, err := src.open()
+			if err != nil {
+				return nil, err
+			}
+			r, err := crypt.Encrypt(rc, key)
+			if err != nil {
+				rc.Close()
+				return nil, err
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{r, rc}, nil
+		},
+		key: &key,
+	}, nil
+}
+
+// estimated reports whether only the estimate of the size is known.
+func (s *source) estimated() bool {
+	return s.size < 0 && s.estimate >= 0
+}
+
+// stdinIsPiped reports whether stdin is a pipe or file rather than a terminal.
+func stdinIsPiped() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+// stdoutIsTerminal reports whether stdout is a terminal rather than a pipe or
+// file.
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
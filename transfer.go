@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"schneider.vip/share/config"
+	"schneider.vip/share/crypt"
+	"schneider.vip/share/provider"
+	"schneider.vip/share/tui"
+	"schneider.vip/share/tui/upload"
+	"schneider.vip/share/urlshortener"
+)
+
+// result is the outcome of uploading and sharing a single source.
+type result struct {
+	src      *source
+	fileID   string
+	link     string
+	longLink string
+	expires  time.Time
+	password string
+	size     int64
+	checksum string
+	// duration is how long the upload took.
+	duration time.Duration
+	err      error
+	// code is the --output json error code of err.
+	code string
+}
+
+// fail records err with its error code.
+func (res *result) fail(code string, err error) {
+	res.code, res.err = code, err
+}
+
+// failedResults returns the results with an error.
+func failedResults(results []*result) []*result {
+	var failed []*result
+	for _, res := range results {
+		if res.err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// succeededResults returns the results without an error.
+func succeededResults(results []*result) []*result {
+	var ok []*result
+	for _, res := range results {
+		if res.err == nil {
+			ok = append(ok, res)
+		}
+	}
+	return ok
+}
+
+// createLink creates the public link for an uploaded result. The key of an
+// encrypted upload is added as URL fragment.
+func createLink(ctx context.Context, prov provider.Provider, res *result, opts provider.LinkOptions) error {
+	link, err := provider.CreateLink(ctx, prov, res.fileID, opts)
+	if err != nil {
+		return err
+	}
+	res.link = link.URL
+	if res.src.key != nil {
+		res.link = crypt.AddKey(link.URL, *res.src.key)
+	}
+	res.expires = link.Expires
+	res.password = link.Password
+	return nil
+}
+
+// shortenLinks replaces the links of results with short ones, keeping the
+// original in longLink. A failing shortener is not fatal since the long links
+// still work.
+func shortenLinks(ctx context.Context, cfg *config.Config, results []*result) {
+	if err := cfg.ResolveShortenerSecrets(); err != nil {
+		log.Printf("Warning: not shortening links: %v\n", err)
+		return
+	}
+	entry := cfg.Shortener
+	if entry == nil {
+		entry = &config.ShortenerEntry{Type: urlshortener.Types[0]}
+	}
+	s, err := urlshortener.New(entry.Type, entry.Settings)
+	if err != nil {
+		log.Printf("Warning: not shortening links: %v\n", err)
+		return
+	}
+	for _, res := range results {
+		short, err := urlshortener.Shorten(ctx, s, res.link)
+		if err != nil {
+			log.Printf("Warning: can't shorten link of %s: %v\n", res.src.name, err)
+			continue
+		}
+		res.longLink, res.link = res.link, short
+	}
+}
+
+// newResults returns an empty result for each source.
+func newResults(sources []*source) []*result {
+	results := make([]*result, len(sources))
+	for i, src := range sources {
+		results[i] = &result{src: src}
+	}
+	return results
+}
+
+// startUploads uploads the sources of results with at most jobs parallel
+// uploads, reporting progress to p unless it is nil. started and done are
+// called before and after each file, if not nil. Wait on the returned
+// WaitGroup for all uploads.
+func startUploads(ctx context.Context, prov provider.Provider, results []*result, jobs int, p *tea.Program, started, done func(i int, res *result)) *sync.WaitGroup {
+	if jobs < 1 {
+		jobs = 1
+	}
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, res := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				if started != nil {
+					started(i, res)
+				}
+				res.fileID, res.err = uploadOne(ctx, prov, res, p, i)
+			case <-ctx.Done():
+				res.err = ctx.Err()
+			}
+			if done != nil {
+				done(i, res)
+			}
+		}()
+	}
+	return &wg
+}
+
+// uploadAll uploads all sources with at most jobs parallel uploads while
+// showing one progress bar per file. It reports whether the user cancelled.
+func uploadAll(ctx context.Context, prov provider.Provider, sources []*source, jobs int) ([]*result, bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	files := make([]upload.File, len(sources))
+	for i, src := range sources {
+		files[i] = upload.File{Name: src.name, Size: src.estimate, Estimated: src.estimated()}
+	}
+	results := newResults(sources)
+
+	model := upload.NewModel(files...)
+	model.SetCancel(cancel)
+	p := tea.NewProgram(model)
+
+	wg := startUploads(ctx, prov, results, jobs, p, nil, func(i int, res *result) {
+		upload.SendDone(p, i, res.fileID, res.err)
+	})
+
+	finalModel, err := p.Run()
+	if err != nil {
+		cancel()
+		wg.Wait()
+		if errors.Is(err, tea.ErrInterrupted) {
+			// SIGINT while the TUI had no keyboard input to catch Ctrl+C.
+			return results, true, nil
+		}
+		return nil, false, err
+	}
+	// Wait for the providers to finish, or to abort the requests and remove
+	// partial files after a cancel.
+	wg.Wait()
+	if m, ok := finalModel.(upload.Model); ok && m.Cancelled() {
+		return results, true, nil
+	}
+	return results, false, nil
+}
+
+// uploadQuiet uploads all sources like uploadAll, but without a TUI. If w is
+// not nil, a line is written to it when each file starts and ends. It reports
+// whether ctx was cancelled, e.g. by SIGINT.
+func uploadQuiet(ctx context.Context, prov provider.Provider, sources []*source, jobs int, w io.Writer) ([]*result, bool) {
+	results := newResults(sources)
+	var started, done func(i int, res *result)
+	if w != nil {
+		var mu sync.Mutex
+		started = func(i int, res *result) {
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintf(w, "[%d/%d] Uploading %s (%s)\n", i+1, len(results), res.src.name, sizeString(res.src))
+		}
+		done = func(i int, res *result) {
+			mu.Lock()
+			defer mu.Unlock()
+			if res.err != nil {
+				fmt.Fprintf(w, "[%d/%d] Failed %s: %v\n", i+1, len(results), res.src.name, res.err)
+				return
+			}
+			fmt.Fprintf(w, "[%d/%d] Uploaded %s (%s in %s)\n", i+1, len(results), res.src.name,
+				tui.HumanBytes(res.size), res.duration.Round(time.Millisecond))
+		}
+	}
+	startUploads(ctx, prov, results, jobs, nil, started, done).Wait()
+	return results, ctx.Err() != nil
+}
+
+// sizeString formats the size of src, which may be estimated or unknown.
+func sizeString(src *source) string {
+	switch {
+	case src.estimate < 0:
+		return "unknown size"
+	case src.estimated():
+		return "about " + tui.HumanBytes(src.estimate)
+	}
+	return tui.HumanBytes(src.size)
+}
+
+// uploadOne opens the source of res and uploads it, recording the number of
+// bytes sent and their SHA-256 checksum in res. Progress is reported to p as
+// the file at index, unless p is nil.
+func uploadOne(ctx context.Context, prov provider.Provider, res *result, p *tea.Program, index int) (string, error) {
+	src := res.src
+	rc, err := src.open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	start := time.Now()
+	h := &hashingReader{r: rc, hash: sha256.New()}
+	var r io.Reader = h
+	if p != nil {
+		r = upload.NewProgressReader(h, src.estimate, p, index)
+	}
+	fileID, err := prov.Upload(ctx, r, src.name, src.size)
+	if err != nil {
+		return "", err
+	}
+	res.size = h.n
+	res.duration = time.Since(start)
+	res.checksum = hex.EncodeToString(h.hash.Sum(nil))
+	return fileID, nil
+}
+
+// hashingReader counts and hashes everything read through it.
+type hashingReader struct {
+	r    io.Reader
+	hash hash.Hash
+	n    int64
+}
+
+func (h *hashingReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.hash.Write(p[:n])
+	h.n += int64(n)
+	return n, err
+}
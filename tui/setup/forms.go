@@ -1,11 +1,41 @@
 package setup
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+
 	"github.com/charmbracelet/huh"
+	"schneider.vip/share/provider"
+	"schneider.vip/share/provider/ftp"
+	"schneider.vip/share/provider/gist"
+	"schneider.vip/share/provider/paste"
+	"schneider.vip/share/provider/s3"
+	"schneider.vip/share/provider/sftp"
+	"schneider.vip/share/provider/webdav"
 )
 
 // ProviderTypes lists all available provider types.
-var ProviderTypes = []string{"httpupload", "nextcloud", "dropbox", "googledrive", "box", "opendrive", "seafile"}
+var ProviderTypes = []string{"httpupload", "nextcloud", "dropbox", "googledrive", "box", "opendrive", "seafile", "s3", "azureblob", "sftp", "ftp", "webdav", "paste", "gist"}
+
+// SettingKeys lists the settings of each provider type, for the forms below
+// and for settings given as environment variables or with --set.
+var SettingKeys = map[string][]string{
+	"httpupload":  {"url", "headers"},
+	"nextcloud":   {"url", "username", "password", "linkShareWithPassword", "randomPasswordChars"},
+	"dropbox":     {"token"},
+	"googledrive": {"googletoken"},
+	"box":         {"token"},
+	"opendrive":   {"user", "pass"},
+	"seafile":     {"url", "token", "repoid"},
+	"s3":          {"endpoint", "region", "bucket", "prefix", "accessKeyID", "secretAccessKey", "pathStyle", "linkExpiry"},
+	"azureblob":   {"connectionString", "accountName", "accountKey", "endpoint", "container", "prefix", "linkExpiry", "concurrency"},
+	"sftp":        {"host", "user", "auth", "keyFile", "passphrase", "password", "knownHosts", "remoteDir", "baseURL"},
+	"ftp":         {"host", "user", "password", "tls", "skipVerify", "disableEPSV", "remoteDir", "linkTemplate"},
+	"webdav":      {"url", "dir", "auth", "username", "password", "token", "linkTemplate"},
+	"paste":       {"preset", "server", "url", "method", "body", "field", "fields", "headers", "linkField", "linkTemplate"},
+	"gist":        {"token", "apiURL", "public", "description", "link", "separate"},
+}
 
 // NextcloudFields holds the form field values for a nextcloud provider.
 type NextcloudFields struct {
@@ -224,6 +254,654 @@ func httpuploadForm(defaults map[string]string) (*huh.Form, *HTTPUploadFields) {
 	return form, f
 }
 
+// S3Fields holds the form field values for an s3 provider.
+type S3Fields struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PathStyle       bool
+	LinkExpiry      string
+}
+
+func (f *S3Fields) ToSettings() map[string]string {
+	pathStyle := "false"
+	if f.PathStyle {
+		pathStyle = "true"
+	}
+	return map[string]string{
+		"endpoint":        f.Endpoint,
+		"region":          f.Region,
+		"bucket":          f.Bucket,
+		"prefix":          f.Prefix,
+		"accessKeyID":     f.AccessKeyID,
+		"secretAccessKey": f.SecretAccessKey,
+		"pathStyle":       pathStyle,
+		"linkExpiry":      f.LinkExpiry,
+	}
+}
+
+func s3Form(defaults map[string]string) (*huh.Form, *S3Fields) {
+	f := &S3Fields{
+		Endpoint:        getDefault(defaults, "endpoint", ""),
+		Region:          getDefault(defaults, "region", s3.DefaultRegion),
+		Bucket:          getDefault(defaults, "bucket", ""),
+		Prefix:          getDefault(defaults, "prefix", "sharecmd/"),
+		AccessKeyID:     getDefault(defaults, "accessKeyID", ""),
+		SecretAccessKey: getDefault(defaults, "secretAccessKey", ""),
+		PathStyle:       getDefault(defaults, "pathStyle", "") == "true",
+		LinkExpiry:      getDefault(defaults, "linkExpiry", "7d"),
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Endpoint").
+				Description("e.g. http://localhost:9000 for MinIO\nLeave empty for AWS S3").
+				Value(&f.Endpoint),
+			huh.NewInput().
+				Title("Region").
+				Value(&f.Region),
+			huh.NewInput().
+				Title("Bucket").
+				Validate(func(s string) error {
+					if s == "" {
+						return fmt.Errorf("bucket is required")
+					}
+					return nil
+				}).
+				Value(&f.Bucket),
+			huh.NewInput().
+				Title("Key prefix").
+				Description("Uploads are stored as <prefix><filename>").
+				Value(&f.Prefix),
+			huh.NewInput().
+				Title("Access key ID").
+				Value(&f.AccessKeyID),
+			huh.NewInput().
+				Title("Secret access key").
+				EchoMode(huh.EchoModePassword).
+				Value(&f.SecretAccessKey),
+			huh.NewConfirm().
+				Title("Path-style URLs?").
+				Description("<endpoint>/<bucket> instead of <bucket>.<endpoint>, needed by MinIO").
+				Value(&f.PathStyle),
+			huh.NewInput().
+				Title("Link lifetime").
+				Description("How long presigned links are valid, at most 7d").
+				Validate(func(s string) error {
+					d, err := provider.ParseExpire(s)
+					if err != nil {
+						return err
+					}
+					if d > s3.MaxLinkExpiry {
+						return fmt.Errorf("at most 7d")
+					}
+					return nil
+				}).
+				Value(&f.LinkExpiry),
+		),
+	)
+	return form, f
+}
+
+// AzureBlobFields holds the form field values for an azureblob provider.
+type AzureBlobFields struct {
+	UseConnectionString bool
+	ConnectionString    string
+	AccountName         string
+	AccountKey          string
+	Endpoint            string
+	Container           string
+	Prefix              string
+	LinkExpiry          string
+}
+
+// ToSettings only keeps the credentials of the chosen method.
+func (f *AzureBlobFields) ToSettings() map[string]string {
+	settings := map[string]string{
+		"container":  f.Container,
+		"prefix":     f.Prefix,
+		"linkExpiry": f.LinkExpiry,
+	}
+	if f.UseConnectionString {
+		settings["connectionString"] = f.ConnectionString
+	} else {
+		settings["accountName"] = f.AccountName
+		settings["accountKey"] = f.AccountKey
+		settings["endpoint"] = f.Endpoint
+	}
+	return settings
+}
+
+func azureblobForm(defaults map[string]string) (*huh.Form, *AzureBlobFields) {
+	f := &AzureBlobFields{
+		ConnectionString: getDefault(defaults, "connectionString", ""),
+		AccountName:      getDefault(defaults, "accountName", ""),
+		AccountKey:       getDefault(defaults, "accountKey", ""),
+		Endpoint:         getDefault(defaults, "endpoint", ""),
+		Container:        getDefault(defaults, "container", "sharecmd"),
+		Prefix:           getDefault(defaults, "prefix", ""),
+		LinkExpiry:       getDefault(defaults, "linkExpiry", "7d"),
+	}
+	f.UseConnectionString = f.ConnectionString != "" || f.AccountName == ""
+
+	required := func(name string) func(string) error {
+		return func(s string) error {
+			if s == "" {
+				return fmt.Errorf("%s is required", name)
+			}
+			return nil
+		}
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[bool]().
+				Title("Credentials").
+				Options(
+					huh.NewOption("Connection string", true),
+					huh.NewOption("Account name and key", false),
+				).
+				Value(&f.UseConnectionString),
+		),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Connection string").
+				Description("Storage account > Access keys in the Azure portal").
+				EchoMode(huh.EchoModePassword).
+				Validate(required("connection string")).
+				Value(&f.ConnectionString),
+		).WithHideFunc(func() bool { return !f.UseConnectionString }),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Account name").
+				Validate(required("account name")).
+				Value(&f.AccountName),
+			huh.NewInput().
+				Title("Account key").
+				EchoMode(huh.EchoModePassword).
+				Validate(required("account key")).
+				Value(&f.AccountKey),
+			huh.NewInput().
+				Title("Blob endpoint").
+				Description("e.g. http://127.0.0.1:10000/devstoreaccount1 for Azurite\nLeave empty for Azure").
+				Value(&f.Endpoint),
+		).WithHideFunc(func() bool { return f.UseConnectionString }),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Container").
+				Description("Created if missing").
+				Validate(required("container")).
+				Value(&f.Container),
+			huh.NewInput().
+				Title("Blob prefix").
+				Description("Uploads are stored as <prefix>/<filename>").
+				Value(&f.Prefix),
+			huh.NewInput().
+				Title("Link lifetime").
+				Description("How long SAS links are valid").
+				Validate(func(s string) error {
+					_, err := provider.ParseExpire(s)
+					return err
+				}).
+				Value(&f.LinkExpiry),
+		),
+	)
+	return form, f
+}
+
+// SFTPFields holds the form field values for an sftp provider.
+type SFTPFields struct {
+	Host       string
+	User       string
+	Auth       string
+	KeyFile    string
+	Passphrase string
+	Password   string
+	KnownHosts string
+	RemoteDir  string
+	BaseURL    string
+}
+
+func (f *SFTPFields) ToSettings() map[string]string {
+	return map[string]string{
+		"host":       f.Host,
+		"user":       f.User,
+		"auth":       f.Auth,
+		"keyFile":    f.KeyFile,
+		"passphrase": f.Passphrase,
+		"password":   f.Password,
+		"knownHosts": f.KnownHosts,
+		"remoteDir":  f.RemoteDir,
+		"baseURL":    f.BaseURL,
+	}
+}
+
+func sftpForm(defaults map[string]string) (*huh.Form, *SFTPFields) {
+	f := &SFTPFields{
+		Host:       getDefault(defaults, "host", ""),
+		User:       getDefault(defaults, "user", os.Getenv("USER")),
+		Auth:       getDefault(defaults, "auth", sftp.AuthAgent),
+		KeyFile:    getDefault(defaults, "keyFile", "~/.ssh/id_ed25519"),
+		Passphrase: getDefault(defaults, "passphrase", ""),
+		Password:   getDefault(defaults, "password", ""),
+		KnownHosts: getDefault(defaults, "knownHosts", ""),
+		RemoteDir:  getDefault(defaults, "remoteDir", "public_html/share"),
+		BaseURL:    getDefault(defaults, "baseURL", ""),
+	}
+
+	authOptions := make([]huh.Option[string], len(sftp.AuthMethods))
+	for i, m := range sftp.AuthMethods {
+		authOptions[i] = huh.NewOption(m, m)
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Host").
+				Description("host or host:port").
+				Value(&f.Host),
+			huh.NewInput().
+				Title("User").
+				Value(&f.User),
+			huh.NewSelect[string]().
+				Title("Authentication").
+				Options(authOptions...).
+				Value(&f.Auth),
+		),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Private key file").
+				Value(&f.KeyFile),
+			huh.NewInput().
+				Title("Key passphrase").
+				Description("Leave empty if the key is not encrypted").
+				EchoMode(huh.EchoModePassword).
+				Value(&f.Passphrase),
+		).WithHideFunc(func() bool { return f.Auth != sftp.AuthKey }),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Password").
+				EchoMode(huh.EchoModePassword).
+				Value(&f.Password),
+		).WithHideFunc(func() bool { return f.Auth != sftp.AuthPassword }),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("known_hosts file").
+				Description("Leave empty for ~/.ssh/known_hosts").
+				Value(&f.KnownHosts),
+			huh.NewInput().
+				Title("Remote directory").
+				Description("Relative to the login directory unless absolute").
+				Value(&f.RemoteDir),
+			huh.NewInput().
+				Title("Base URL").
+				Description("Public URL of the remote directory\ne.g. https://example.com/~me/share/").
+				Value(&f.BaseURL),
+		),
+	)
+	return form, f
+}
+
+// FTPFields holds the form field values for an ftp provider.
+type FTPFields struct {
+	Host         string
+	User         string
+	Password     string
+	TLS          string
+	SkipVerify   bool
+	DisableEPSV  bool
+	RemoteDir    string
+	LinkTemplate string
+}
+
+func (f *FTPFields) ToSettings() map[string]string {
+	return map[string]string{
+		"host":         f.Host,
+		"user":         f.User,
+		"password":     f.Password,
+		"tls":          f.TLS,
+		"skipVerify":   fmt.Sprint(f.SkipVerify),
+		"disableEPSV":  fmt.Sprint(f.DisableEPSV),
+		"remoteDir":    f.RemoteDir,
+		"linkTemplate": f.LinkTemplate,
+	}
+}
+
+func ftpForm(defaults map[string]string) (*huh.Form, *FTPFields) {
+	f := &FTPFields{
+		Host:         getDefault(defaults, "host", ""),
+		User:         getDefault(defaults, "user", ""),
+		Password:     getDefault(defaults, "password", ""),
+		TLS:          getDefault(defaults, "tls", ftp.TLSExplicit),
+		SkipVerify:   getDefault(defaults, "skipVerify", "") == "true",
+		DisableEPSV:  getDefault(defaults, "disableEPSV", "") == "true",
+		RemoteDir:    getDefault(defaults, "remoteDir", ""),
+		LinkTemplate: getDefault(defaults, "linkTemplate", ""),
+	}
+
+	tlsOptions := make([]huh.Option[string], len(ftp.TLSModes))
+	for i, m := range ftp.TLSModes {
+		tlsOptions[i] = huh.NewOption(m, m)
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Host").
+				Description("host or host:port").
+				Validate(func(s string) error {
+					if s == "" {
+						return fmt.Errorf("host is required")
+					}
+					return nil
+				}).
+				Value(&f.Host),
+			huh.NewInput().
+				Title("User").
+				Description("Leave empty to log in anonymously").
+				Value(&f.User),
+			huh.NewInput().
+				Title("Password").
+				EchoMode(huh.EchoModePassword).
+				Value(&f.Password),
+			huh.NewSelect[string]().
+				Title("TLS").
+				Description("explicit: AUTH TLS on port 21, implicit: FTPS on port 990").
+				Options(tlsOptions...).
+				Value(&f.TLS),
+		),
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Accept any certificate?").
+				Description("Only for self-signed certificates").
+				Value(&f.SkipVerify),
+		).WithHideFunc(func() bool { return f.TLS == ftp.TLSNone }),
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Use PASV instead of EPSV?").
+				Description("Needed by some servers behind NAT").
+				Value(&f.DisableEPSV),
+			huh.NewInput().
+				Title("Remote directory").
+				Description("Relative to the login directory unless absolute").
+				Value(&f.RemoteDir),
+			huh.NewInput().
+				Title("Link template").
+				Description("Public link of an upload, e.g. https://example.com/drop/{{.Name}}\nLeave empty to share the ftp:// URL").
+				Value(&f.LinkTemplate),
+		),
+	)
+	return form, f
+}
+
+// WebDAVFields holds the form field values for a webdav provider.
+type WebDAVFields struct {
+	URL          string
+	Dir          string
+	Auth         string
+	Username     string
+	Password     string
+	Token        string
+	LinkTemplate string
+}
+
+func (f *WebDAVFields) ToSettings() map[string]string {
+	return map[string]string{
+		"url":          f.URL,
+		"dir":          f.Dir,
+		"auth":         f.Auth,
+		"username":     f.Username,
+		"password":     f.Password,
+		"token":        f.Token,
+		"linkTemplate": f.LinkTemplate,
+	}
+}
+
+func webdavForm(defaults map[string]string) (*huh.Form, *WebDAVFields) {
+	f := &WebDAVFields{
+		URL:          getDefault(defaults, "url", ""),
+		Dir:          getDefault(defaults, "dir", "sharecmd"),
+		Auth:         getDefault(defaults, "auth", webdav.AuthBasic),
+		Username:     getDefault(defaults, "username", ""),
+		Password:     getDefault(defaults, "password", ""),
+		Token:        getDefault(defaults, "token", ""),
+		LinkTemplate: getDefault(defaults, "linkTemplate", ""),
+	}
+
+	authOptions := make([]huh.Option[string], len(webdav.AuthMethods))
+	for i, m := range webdav.AuthMethods {
+		authOptions[i] = huh.NewOption(m, m)
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("WebDAV URL").
+				Description("e.g. https://dav.example.com/files/").
+				Value(&f.URL),
+			huh.NewInput().
+				Title("Directory").
+				Description("Collection for uploads, created if missing").
+				Value(&f.Dir),
+			huh.NewSelect[string]().
+				Title("Authentication").
+				Options(authOptions...).
+				Value(&f.Auth),
+		),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Username").
+				Value(&f.Username),
+			huh.NewInput().
+				Title("Password").
+				EchoMode(huh.EchoModePassword).
+				Value(&f.Password),
+		).WithHideFunc(func() bool { return f.Auth != webdav.AuthBasic && f.Auth != webdav.AuthDigest }),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Bearer token").
+				EchoMode(huh.EchoModePassword).
+				Value(&f.Token),
+		).WithHideFunc(func() bool { return f.Auth != webdav.AuthBearer }),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Link template").
+				Description("Public link of an upload, e.g. https://example.com/share/{{.Name}}\nLeave empty to share the WebDAV URL").
+				Value(&f.LinkTemplate),
+		),
+	)
+	return form, f
+}
+
+// PasteFields holds the form field values for a paste provider.
+type PasteFields struct {
+	Preset       string
+	Server       string
+	URL          string
+	Method       string
+	Body         string
+	Field        string
+	Fields       string
+	Headers      string
+	LinkField    string
+	LinkTemplate string
+}
+
+func (f *PasteFields) ToSettings() map[string]string {
+	settings := map[string]string{
+		"preset":  f.Preset,
+		"server":  f.Server,
+		"headers": f.Headers,
+	}
+	if f.Preset == paste.PresetCustom {
+		settings["url"] = f.URL
+		settings["method"] = f.Method
+		settings["body"] = f.Body
+		settings["field"] = f.Field
+		settings["fields"] = f.Fields
+		settings["linkField"] = f.LinkField
+		settings["linkTemplate"] = f.LinkTemplate
+	}
+	return settings
+}
+
+func pasteForm(defaults map[string]string) (*huh.Form, *PasteFields) {
+	f := &PasteFields{
+		Preset:       getDefault(defaults, "preset", paste.Preset0x0),
+		Server:       getDefault(defaults, "server", ""),
+		URL:          getDefault(defaults, "url", "{{.Server}}/api/paste"),
+		Method:       getDefault(defaults, "method", "POST"),
+		Body:         getDefault(defaults, "body", paste.BodyJSON),
+		Field:        getDefault(defaults, "field", ""),
+		Fields:       getDefault(defaults, "fields", "{}"),
+		Headers:      getDefault(defaults, "headers", "{}"),
+		LinkField:    getDefault(defaults, "linkField", ""),
+		LinkTemplate: getDefault(defaults, "linkTemplate", ""),
+	}
+
+	presetOptions := make([]huh.Option[string], len(paste.Presets))
+	for i, p := range paste.Presets {
+		presetOptions[i] = huh.NewOption(p, p)
+	}
+	validJSON := func(s string) error {
+		if s == "" {
+			return nil
+		}
+		var m map[string]string
+		if err := json.Unmarshal([]byte(s), &m); err != nil {
+			return fmt.Errorf("must be a JSON object of strings")
+		}
+		return nil
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Paste service").
+				Options(presetOptions...).
+				Value(&f.Preset),
+			huh.NewInput().
+				Title("Server URL").
+				Description("e.g. https://hastebin.example.com\nLeave empty for the public 0x0.st or transfer.sh").
+				Value(&f.Server),
+		),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Endpoint").
+				Description("Template with {{.Server}}, {{.Name}}, {{.Language}} and {{.Ext}}").
+				Value(&f.URL),
+			huh.NewSelect[string]().
+				Title("Method").
+				Options(huh.NewOptions("POST", "PUT")...).
+				Value(&f.Method),
+			huh.NewSelect[string]().
+				Title("Body").
+				Options(huh.NewOptions(paste.BodyJSON, paste.BodyForm, paste.BodyRaw)...).
+				Value(&f.Body),
+			huh.NewInput().
+				Title("Content field").
+				Description("Form field or JSON key of the text\nLeave empty for \"file\" (form) or \"content\" (JSON)").
+				Value(&f.Field),
+			huh.NewText().
+				Title("Extra fields (JSON)").
+				Description("e.g. {\"syntax\": \"{{.Language}}\", \"expire\": \"1day\"}").
+				Validate(validJSON).
+				Value(&f.Fields),
+			huh.NewInput().
+				Title("Link field").
+				Description("Dotted path of the link or key in a JSON reply, e.g. data.url\nLeave empty if the reply is the link").
+				Value(&f.LinkField),
+			huh.NewInput().
+				Title("Link template").
+				Description("e.g. {{.Server}}/{{.Value}}.{{.Ext}}\nLeave empty to use the reply as link").
+				Value(&f.LinkTemplate),
+		).WithHideFunc(func() bool { return f.Preset != paste.PresetCustom }),
+		huh.NewGroup(
+			huh.NewText().
+				Title("Custom HTTP Headers (JSON)").
+				Description("e.g. {\"Authorization\": \"Bearer token\"}").
+				Validate(validJSON).
+				Value(&f.Headers),
+		),
+	)
+	return form, f
+}
+
+// GistFields holds the form field values for a gist provider.
+type GistFields struct {
+	Token       string
+	APIURL      string
+	Public      bool
+	Description string
+	Link        string
+	Separate    bool
+}
+
+func (f *GistFields) ToSettings() map[string]string {
+	return map[string]string{
+		"token":       f.Token,
+		"apiURL":      f.APIURL,
+		"public":      fmt.Sprint(f.Public),
+		"description": f.Description,
+		"link":        f.Link,
+		"separate":    fmt.Sprint(f.Separate),
+	}
+}
+
+func gistForm(defaults map[string]string) (*huh.Form, *GistFields) {
+	f := &GistFields{
+		Token:       getDefault(defaults, "token", ""),
+		APIURL:      getDefault(defaults, "apiURL", gist.DefaultAPIURL),
+		Public:      getDefault(defaults, "public", "") == "true",
+		Description: getDefault(defaults, "description", "Shared with sharecmd"),
+		Link:        getDefault(defaults, "link", gist.LinkHTML),
+		Separate:    getDefault(defaults, "separate", "") == "true",
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Personal access token").
+				Description("Needs the gist scope (classic) or Gists read/write permission").
+				EchoMode(huh.EchoModePassword).
+				Validate(func(s string) error {
+					if s == "" {
+						return fmt.Errorf("token is required")
+					}
+					return nil
+				}).
+				Value(&f.Token),
+			huh.NewInput().
+				Title("API URL").
+				Description("https://<host>/api/v3 for GitHub Enterprise").
+				Value(&f.APIURL),
+			huh.NewConfirm().
+				Title("Public gists?").
+				Description("Secret gists are unlisted but visible to anyone with the link").
+				Value(&f.Public),
+			huh.NewInput().
+				Title("Description").
+				Value(&f.Description),
+			huh.NewSelect[string]().
+				Title("Link").
+				Options(
+					huh.NewOption("Gist page", gist.LinkHTML),
+					huh.NewOption("Raw file", gist.LinkRaw),
+				).
+				Value(&f.Link),
+			huh.NewConfirm().
+				Title("One gist per file?").
+				Description("By default all files shared at once go into one gist").
+				Value(&f.Separate),
+		),
+	)
+	return form, f
+}
+
 func getDefault(m map[string]string, key, fallback string) string {
 	if m == nil {
 		return fallback
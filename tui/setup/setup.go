@@ -3,19 +3,31 @@ package setup
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
 	"golang.org/x/oauth2"
+	"schneider.vip/share/archive"
 	"schneider.vip/share/config"
+	"schneider.vip/share/provider"
 	"schneider.vip/share/provider/box"
 	"schneider.vip/share/provider/dropbox"
 	"schneider.vip/share/provider/googledrive"
 	"schneider.vip/share/provider/seafile"
+	"schneider.vip/share/secret"
 	"schneider.vip/share/tui"
+	"schneider.vip/share/urlshortener"
 )
 
+// Check verifies the settings and credentials of a provider after it was
+// added, see provider.Check. It is set by the caller, since creating a
+// provider is not up to setup; nil skips the check.
+var Check func(cfg *config.Config, entry *config.ProviderEntry) error
+
 // Run launches the interactive setup TUI. It loops a main menu until the user quits.
 func Run(cfg *config.Config) error {
 	for {
@@ -172,7 +184,9 @@ func addProvider(cfg *config.Config) error {
 		Type:     provType,
 		Settings: settings,
 	}
-	cfg.AddProvider(entry)
+	if err := cfg.AddProvider(entry); err != nil {
+		return err
+	}
 
 	// If this is the only provider, set it as active.
 	if len(cfg.Providers) == 1 {
@@ -183,9 +197,28 @@ func addProvider(cfg *config.Config) error {
 		return err
 	}
 	fmt.Println(tui.Success.Render(fmt.Sprintf("Provider %q added.", *label)))
+	checkAdded(cfg, cfg.FindByLabel(*label))
 	return nil
 }
 
+// checkAdded runs Check for a new provider. A failure is only reported,
+// since the provider is saved and can be fixed with "Edit provider".
+func checkAdded(cfg *config.Config, entry *config.ProviderEntry) {
+	if Check == nil || entry == nil {
+		return
+	}
+	fmt.Println(tui.Subtle.Render("Checking the connection..."))
+	err := Check(cfg, entry)
+	switch {
+	case errors.Is(err, provider.ErrUnsupported):
+	case err != nil:
+		fmt.Println(tui.Error.Render(fmt.Sprintf("Connection check failed: %v", err)))
+		fmt.Println(tui.Subtle.Render("Fix the settings with \"Edit provider\", or run 'share doctor' later."))
+	default:
+		fmt.Println(tui.Success.Render("Connection check passed."))
+	}
+}
+
 func editProvider(cfg *config.Config) error {
 	if len(cfg.Providers) == 0 {
 		fmt.Println(tui.Error.Render("No providers to edit."))
@@ -201,6 +234,10 @@ func editProvider(cfg *config.Config) error {
 	if entry == nil {
 		return fmt.Errorf("provider %q not found", label)
 	}
+	// Show the secrets themselves, and replace them in the store on save.
+	if err := cfg.ResolveSecrets(entry); err != nil {
+		return err
+	}
 
 	settings, err := runProviderForm(entry.Type, entry.Settings)
 	if err != nil {
@@ -251,9 +288,35 @@ func deleteProvider(cfg *config.Config) error {
 }
 
 func editPreferences(cfg *config.Config) error {
+	// Show the shortener secrets themselves, and replace them on save.
+	if err := cfg.ResolveShortenerSecrets(); err != nil {
+		return err
+	}
+
 	copyClip := cfg.CopyToClipboardEnabled()
 	showQR := cfg.ShowQRCodeEnabled()
 	sixel := cfg.IsSixelEnabled()
+	archiveFormat := cfg.ArchiveFormatOrDefault()
+	recordHistory := cfg.HistoryEnabled()
+	shortenLinks := cfg.ShortenLinksEnabled()
+	shortenerType := urlshortener.Types[0]
+	if cfg.Shortener != nil {
+		shortenerType = cfg.Shortener.Type
+	}
+	secretStore := cfg.SecretStore
+
+	formatOptions := make([]huh.Option[string], len(archive.Formats))
+	for i, f := range archive.Formats {
+		formatOptions[i] = huh.NewOption(f, f)
+	}
+	storeOptions := []huh.Option[string]{
+		huh.NewOption("Config file (plaintext)", ""),
+		huh.NewOption("System keyring", secret.Keyring),
+		huh.NewOption("File encrypted with a passphrase", secret.File),
+	}
+	if secretStore != "" && secretStore != secret.Keyring && secretStore != secret.File {
+		storeOptions = append(storeOptions, huh.NewOption(secretStore, secretStore))
+	}
 
 	form := huh.NewForm(
 		huh.NewGroup(
@@ -267,23 +330,94 @@ func editPreferences(cfg *config.Config) error {
 				Title("Use Sixel graphics for QR code?").
 				Description("Sixel renders the QR code as a pixel image. Disable if your terminal does not support it (e.g. ttyd).").
 				Value(&sixel),
+			huh.NewSelect[string]().
+				Title("Archive format for directories").
+				Options(formatOptions...).
+				Value(&archiveFormat),
+			huh.NewConfirm().
+				Title("Record uploads in history?").
+				Description("Keeps links of past uploads for 'share history'.").
+				Value(&recordHistory),
+			huh.NewConfirm().
+				Title("Shorten links by default?").
+				Description("Otherwise use --short to shorten the links of one upload.").
+				Value(&shortenLinks),
+			huh.NewSelect[string]().
+				Title("URL shortener").
+				Options(huh.NewOptions(urlshortener.Types...)...).
+				Value(&shortenerType),
+			huh.NewSelect[string]().
+				Title("Store passwords and tokens in").
+				Description("Use 'share secrets migrate exec:COMMAND' for a helper like pass.").
+				Options(storeOptions...).
+				Value(&secretStore),
 		),
 	)
 	if err := form.Run(); err != nil {
 		return err
 	}
 
+	var current map[string]string
+	if cfg.Shortener != nil && cfg.Shortener.Type == shortenerType {
+		current = cfg.Shortener.Settings
+	}
+	shortenerSettings, err := askShortenerSettings(shortenerType, current)
+	if err != nil {
+		return err
+	}
+
 	cfg.CopyToClipboard = &copyClip
 	cfg.ShowQRCode = &showQR
 	cfg.SixelEnabled = &sixel
+	cfg.ArchiveFormat = archiveFormat
+	cfg.History = &recordHistory
+	cfg.ShortenLinks = &shortenLinks
+	// Keep the entry, so that its secrets are replaced rather than leaked.
+	if cfg.Shortener == nil {
+		cfg.Shortener = &config.ShortenerEntry{}
+	}
+	cfg.Shortener.Type, cfg.Shortener.Settings = shortenerType, shortenerSettings
 
-	if err := cfg.Write(); err != nil {
+	// MigrateSecrets writes the config as well.
+	if err := cfg.MigrateSecrets(secretStore); err != nil {
 		return err
 	}
 	fmt.Println(tui.Success.Render("Preferences saved."))
 	return nil
 }
 
+// askShortenerSettings asks the setup questions of the URL shortener type,
+// defaulting to the current settings.
+func askShortenerSettings(typ string, current map[string]string) (map[string]string, error) {
+	s, err := urlshortener.New(typ, current)
+	if err != nil {
+		return nil, err
+	}
+	questions := s.SetupQuestions()
+	if len(questions) == 0 {
+		return nil, nil
+	}
+
+	keys := slices.Sorted(maps.Keys(questions))
+	values := make([]string, len(keys))
+	fields := make([]huh.Field, len(keys))
+	for i, key := range keys {
+		values[i] = current[key]
+		fields[i] = huh.NewInput().
+			Title(questions[key]).
+			Value(&values[i])
+	}
+	if err := huh.NewForm(huh.NewGroup(fields...)).Run(); err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]string, len(keys))
+	for i, key := range keys {
+		settings[key] = values[i]
+	}
+	return settings, nil
+}
+
 func pickProvider(cfg *config.Config, title string) (string, error) {
 	return PickProvider(cfg, title)
 }
@@ -325,8 +459,17 @@ func ReconfigureProvider(cfg *config.Config, label string) error {
 		return err
 	}
 
+	// An upload is running, so only replace the settings of this provider.
 	entry.Settings = settings
-	if err := cfg.Write(); err != nil {
+	err = cfg.Update(func(fresh *config.Config) error {
+		e := fresh.FindByLabel(label)
+		if e == nil {
+			return fmt.Errorf("provider %q was removed", label)
+		}
+		e.Settings = maps.Clone(settings)
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
@@ -382,6 +525,55 @@ func runProviderForm(provType string, defaults map[string]string) (map[string]st
 		}
 		return fields.ToSettings(), nil
 
+	case "s3":
+		form, fields := s3Form(defaults)
+		if err := form.Run(); err != nil {
+			return nil, err
+		}
+		return fields.ToSettings(), nil
+
+	case "azureblob":
+		form, fields := azureblobForm(defaults)
+		if err := form.Run(); err != nil {
+			return nil, err
+		}
+		return fields.ToSettings(), nil
+
+	case "sftp":
+		form, fields := sftpForm(defaults)
+		if err := form.Run(); err != nil {
+			return nil, err
+		}
+		return fields.ToSettings(), nil
+
+	case "ftp":
+		form, fields := ftpForm(defaults)
+		if err := form.Run(); err != nil {
+			return nil, err
+		}
+		return fields.ToSettings(), nil
+
+	case "webdav":
+		form, fields := webdavForm(defaults)
+		if err := form.Run(); err != nil {
+			return nil, err
+		}
+		return fields.ToSettings(), nil
+
+	case "paste":
+		form, fields := pasteForm(defaults)
+		if err := form.Run(); err != nil {
+			return nil, err
+		}
+		return fields.ToSettings(), nil
+
+	case "gist":
+		form, fields := gistForm(defaults)
+		if err := form.Run(); err != nil {
+			return nil, err
+		}
+		return fields.ToSettings(), nil
+
 	case "dropbox":
 		conf := dropbox.OAuth2DropboxConfig()
 		authURL := conf.AuthCodeURL("state", oauth2.SetAuthURLParam("token_access_type", "offline"))
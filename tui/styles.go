@@ -1,6 +1,10 @@
 package tui
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
 
 var (
 	Title   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212"))
@@ -12,3 +16,17 @@ var (
 		BorderForeground(lipgloss.Color("63")).
 		Padding(0, 1)
 )
+
+// HumanBytes formats a byte count with binary units, e.g. "1.5 MiB".
+func HumanBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -1,6 +1,7 @@
 package upload
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"strings"
@@ -16,6 +17,7 @@ import (
 // ProgressReader wraps an io.Reader and reports progress to a Bubble Tea program.
 type ProgressReader struct {
 	reader  io.Reader
+	index   int
 	total   int64
 	read    int64
 	program *tea.Program
@@ -23,19 +25,23 @@ type ProgressReader struct {
 }
 
 type progressMsg struct {
+	index     int
 	percent   float64
 	bytesRead int64
 }
 
 type uploadDoneMsg struct {
+	index  int
 	fileID string
 	err    error
 }
 
-// NewProgressReader creates a reader that sends progress updates to the program.
-func NewProgressReader(r io.Reader, size int64, p *tea.Program) *ProgressReader {
+// NewProgressReader creates a reader that sends progress updates for the
+// file at index to the program.
+func NewProgressReader(r io.Reader, size int64, p *tea.Program, index int) *ProgressReader {
 	return &ProgressReader{
 		reader:  r,
+		index:   index,
 		total:   size,
 		program: p,
 	}
@@ -46,19 +52,33 @@ func (pr *ProgressReader) Read(p []byte) (int, error) {
 	if n > 0 {
 		pr.mu.Lock()
 		pr.read += int64(n)
-		pct := float64(pr.read) / float64(pr.total)
+		var pct float64
+		if pr.total > 0 {
+			pct = float64(pr.read) / float64(pr.total)
+		}
 		read := pr.read
 		pr.mu.Unlock()
-		pr.program.Send(progressMsg{percent: pct, bytesRead: read})
+		pr.program.Send(progressMsg{index: pr.index, percent: pct, bytesRead: read})
 	}
 	return n, err
 }
 
-// Model is the Bubble Tea model for the upload progress screen.
-type Model struct {
+// File describes one file shown on the upload progress screen. A negative
+// Size means the length is unknown; only bytes and speed are shown then.
+type File struct {
+	Name string
+	Size int64
+	// Estimated is set if Size is only expected, like for an archive that is
+	// built while uploading.
+	Estimated bool
+}
+
+// fileState is the progress of a single file.
+type fileState struct {
 	progress  progress.Model
 	filename  string
 	filesize  int64
+	estimated bool
 	percent   float64
 	bytesRead int64
 	speed     float64 // bytes per second
@@ -67,22 +87,45 @@ type Model struct {
 	done      bool
 	fileID    string
 	err       error
+}
+
+// Model is the Bubble Tea model for the upload progress screen. It shows one
+// progress bar per file.
+type Model struct {
+	files     []*fileState
+	cancelled bool
+	cancel    context.CancelFunc
 	url       string
 	qr        string
 }
 
 // NewModel creates a new upload progress model.
-func NewModel(filename string, filesize int64) Model {
-	p := progress.New(
-		progress.WithDefaultGradient(),
-		progress.WithWidth(40),
-	)
-	return Model{
-		progress: p,
-		filename: filename,
-		filesize: filesize,
-		lastTime: time.Now(),
+func NewModel(files ...File) Model {
+	m := Model{files: make([]*fileState, len(files))}
+	for i, f := range files {
+		m.files[i] = &fileState{
+			progress: progress.New(
+				progress.WithDefaultGradient(),
+				progress.WithWidth(40),
+			),
+			filename:  f.Name,
+			filesize:  f.Size,
+			estimated: f.Estimated,
+			lastTime:  time.Now(),
+		}
 	}
+	return m
+}
+
+// SetCancel registers the function that aborts the running uploads when the
+// user quits the progress screen.
+func (m *Model) SetCancel(cancel context.CancelFunc) {
+	m.cancel = cancel
+}
+
+// Cancelled reports whether the user quit before all uploads finished.
+func (m Model) Cancelled() bool {
+	return m.cancelled
 }
 
 // SetResult sets the final URL and QR code after upload completes.
@@ -91,6 +134,15 @@ func (m *Model) SetResult(url, qr string) {
 	m.qr = qr
 }
 
+func (m Model) allDone() bool {
+	for _, f := range m.files {
+		if !f.done {
+			return false
+		}
+	}
+	return true
+}
+
 func (m Model) Init() tea.Cmd {
 	return nil
 }
@@ -99,34 +151,55 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		if msg.String() == "q" || msg.String() == "ctrl+c" {
+			if !m.allDone() {
+				m.cancelled = true
+				if m.cancel != nil {
+					m.cancel()
+				}
+			}
 			return m, tea.Quit
 		}
 	case progressMsg:
-		m.percent = msg.percent
-		if m.percent >= 1.0 {
-			m.percent = 1.0
+		if msg.index < 0 || msg.index >= len(m.files) {
+			return m, nil
+		}
+		f := m.files[msg.index]
+		f.percent = msg.percent
+		if f.percent >= 1.0 {
+			f.percent = 1.0
 		}
-		m.bytesRead = msg.bytesRead
+		f.bytesRead = msg.bytesRead
 
 		now := time.Now()
-		dt := now.Sub(m.lastTime).Seconds()
+		dt := now.Sub(f.lastTime).Seconds()
 		if dt >= 0.5 {
-			delta := m.bytesRead - m.lastBytes
-			m.speed = float64(delta) / dt
-			m.lastBytes = m.bytesRead
-			m.lastTime = now
+			delta := f.bytesRead - f.lastBytes
+			f.speed = float64(delta) / dt
+			f.lastBytes = f.bytesRead
+			f.lastTime = now
 		}
 
-		return m, m.progress.SetPercent(m.percent)
+		return m, f.progress.SetPercent(f.percent)
 	case uploadDoneMsg:
-		m.done = true
-		m.fileID = msg.fileID
-		m.err = msg.err
-		return m, tea.Quit
+		if msg.index < 0 || msg.index >= len(m.files) {
+			return m, nil
+		}
+		f := m.files[msg.index]
+		f.done = true
+		f.fileID = msg.fileID
+		f.err = msg.err
+		if m.allDone() {
+			return m, tea.Quit
+		}
+		return m, nil
 	case progress.FrameMsg:
-		progressModel, cmd := m.progress.Update(msg)
-		m.progress = progressModel.(progress.Model)
-		return m, cmd
+		var cmds []tea.Cmd
+		for _, f := range m.files {
+			progressModel, cmd := f.progress.Update(msg)
+			f.progress = progressModel.(progress.Model)
+			cmds = append(cmds, cmd)
+		}
+		return m, tea.Batch(cmds...)
 	}
 	return m, nil
 }
@@ -134,9 +207,14 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m Model) View() string {
 	var b strings.Builder
 
-	if m.done {
-		if m.err != nil {
-			b.WriteString(tui.Error.Render("Upload failed: " + m.err.Error()))
+	if m.cancelled {
+		return tui.Error.Render("Upload cancelled.") + "\n"
+	}
+
+	if m.allDone() && len(m.files) == 1 {
+		f := m.files[0]
+		if f.err != nil {
+			b.WriteString(tui.Error.Render("Upload failed: " + f.err.Error()))
 			b.WriteString("\n")
 		} else {
 			b.WriteString(tui.Success.Render("Upload complete!"))
@@ -151,36 +229,52 @@ func (m Model) View() string {
 		return b.String()
 	}
 
-	b.WriteString(tui.Title.Render("Uploading"))
-	b.WriteString(" ")
-	b.WriteString(lipgloss.NewStyle().Bold(true).Render(m.filename))
-	b.WriteString("\n\n")
-	b.WriteString(m.progress.View())
-	fmt.Fprintf(&b, "\n%s / %s",
-		humanBytes(m.bytesRead),
-		humanBytes(m.filesize))
-	if m.speed > 0 {
-		fmt.Fprintf(&b, "  %s/s", humanBytes(int64(m.speed)))
+	for i, f := range m.files {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		f.view(&b)
 	}
-	b.WriteString("\n")
 
 	return b.String()
 }
 
-// SendDone sends an uploadDoneMsg to the program.
-func SendDone(p *tea.Program, fileID string, err error) {
-	p.Send(uploadDoneMsg{fileID: fileID, err: err})
-}
-
-func humanBytes(b int64) string {
-	const unit = 1024
-	if b < unit {
-		return fmt.Sprintf("%d B", b)
+func (f *fileState) view(b *strings.Builder) {
+	switch {
+	case f.done && f.err != nil:
+		b.WriteString(tui.Error.Render("Failed"))
+	case f.done:
+		b.WriteString(tui.Success.Render("Uploaded"))
+	default:
+		b.WriteString(tui.Title.Render("Uploading"))
 	}
-	div, exp := int64(unit), 0
-	for n := b / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+	b.WriteString(" ")
+	b.WriteString(lipgloss.NewStyle().Bold(true).Render(f.filename))
+	b.WriteString("\n")
+	if f.done && f.err != nil {
+		b.WriteString(tui.Subtle.Render(f.err.Error()))
+		b.WriteString("\n")
+		return
+	}
+	b.WriteString("\n")
+	if f.filesize < 0 {
+		// Indeterminate: the total is unknown (e.g. reading from stdin).
+		fmt.Fprintf(b, "%s sent", tui.HumanBytes(f.bytesRead))
+	} else {
+		b.WriteString(f.progress.View())
+		total := tui.HumanBytes(f.filesize)
+		if f.estimated {
+			total = "~" + total
+		}
+		fmt.Fprintf(b, "\n%s / %s", tui.HumanBytes(f.bytesRead), total)
 	}
-	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+	if f.speed > 0 && !f.done {
+		fmt.Fprintf(b, "  %s/s", tui.HumanBytes(int64(f.speed)))
+	}
+	b.WriteString("\n")
+}
+
+// SendDone sends an uploadDoneMsg for the file at index to the program.
+func SendDone(p *tea.Program, index int, fileID string, err error) {
+	p.Send(uploadDoneMsg{index: index, fileID: fileID, err: err})
 }
@@ -1,21 +1,26 @@
 package biturl
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"mime/multipart"
 	"net/http"
-	"strings"
 )
 
+// DefaultAPIURL is the shortening endpoint of biturl.top.
+const DefaultAPIURL = "https://api.biturl.top/short"
+
 // BitURL Shortener Interface impl
 type BitURL struct {
-	URL string
+	APIURL string
 }
 
 // New creates a new BitURL
-func New(url string) *BitURL {
-	return &BitURL{URL: url}
+func New() *BitURL {
+	return &BitURL{APIURL: DefaultAPIURL}
 }
 
 // SetupQuestions will ask for user api key or something else
@@ -24,24 +29,29 @@ func (b *BitURL) SetupQuestions() map[string]string {
 	return make(map[string]string)
 }
 
-// ShortURL maks http post to biturl.top to get short url
+// GetName returns the shortener type
 func (b *BitURL) GetName() string {
 	return "biturl"
 }
 
 // ShortURL maks http post to biturl.top to get short url
-func (b *BitURL) ShortURL() (string, error) {
-	body := `-----------------------------4139599310243699752647233313
-Content-Disposition: form-data; name="url"
+func (b *BitURL) ShortURL(ctx context.Context, longURL string) (string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("url", longURL)
+	mw.Close()
 
-` + b.URL + `
------------------------------4139599310243699752647233313--`
-	resp, err := http.Post("https://api.biturl.top/short", "multipart/form-data; boundary=---------------------------4139599310243699752647233313", strings.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", b.APIURL, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
-	resultBody, err := ioutil.ReadAll(resp.Body)
+	resultBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", fmt.Errorf("result body error: %s, expecting json got: %s", err.Error(), string(resultBody))
 	}
@@ -0,0 +1,35 @@
+package biturl
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestShortURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("url") != "https://example.com/a?b=c" {
+			io.WriteString(w, `{"result":false,"short":"","message":"invalid url"}`)
+			return
+		}
+		io.WriteString(w, `{"result":true,"short":"https://biturl.top/EbQjye","message":""}`)
+	}))
+	defer srv.Close()
+
+	b := New()
+	b.APIURL = srv.URL
+	short, err := b.ShortURL(context.Background(), "https://example.com/a?b=c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if short != "https://biturl.top/EbQjye" {
+		t.Errorf("short = %s", short)
+	}
+
+	if _, err := b.ShortURL(context.Background(), "nope"); err == nil || !strings.Contains(err.Error(), "invalid url") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package jsontemplate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// DefaultBody is used when no body template is configured.
+const DefaultBody = `{"url": {{json .URL}}}`
+
+// JSONTemplate shortens links with any JSON API: the request body is a
+// template and the short URL is read from a field of the reply.
+type JSONTemplate struct {
+	// URL is the API endpoint the body is POSTed to.
+	URL string
+	// Body is a text/template executed with Data; {{json .URL}} inserts the
+	// long URL as JSON string. Defaults to DefaultBody.
+	Body string
+	// Headers are extra request headers as "Name: value" separated by
+	// newlines or semicolons, e.g. "Authorization: Bearer xyz".
+	Headers string
+	// Field is the dotted path of the short URL in the reply, e.g.
+	// "data.short_url".
+	Field string
+}
+
+// Data is passed to the body template.
+type Data struct {
+	URL string
+}
+
+// New creates a new JSON template shortener
+func New(url, body, headers, field string) *JSONTemplate {
+	return &JSONTemplate{URL: url, Body: body, Headers: headers, Field: field}
+}
+
+// GetName returns the shortener type
+func (j *JSONTemplate) GetName() string {
+	return "json"
+}
+
+// SetupQuestions asks for the endpoint, body template, headers and field
+func (j *JSONTemplate) SetupQuestions() map[string]string {
+	return map[string]string{
+		"url":     "API endpoint (POST)",
+		"body":    "Body template (empty for " + DefaultBody + ")",
+		"headers": "Headers, e.g. Authorization: Bearer xyz; X-Other: 1",
+		"field":   "Field of the short URL in the reply, e.g. data.short_url",
+	}
+}
+
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// ShortURL POSTs the body template for longURL and returns the short URL
+// found at Field in the reply
+func (j *JSONTemplate) ShortURL(ctx context.Context, longURL string) (string, error) {
+	if j.URL == "" || j.Field == "" {
+		return "", errors.New("url and field are required")
+	}
+	body := j.Body
+	if body == "" {
+		body = DefaultBody
+	}
+	t, err := template.New("body").Funcs(funcs).Parse(body)
+	if err != nil {
+		return "", fmt.Errorf("invalid body template: %w", err)
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, Data{URL: longURL}); err != nil {
+		return "", fmt.Errorf("body template: %w", err)
+	}
+	if !json.Valid(b.Bytes()) {
+		return "", fmt.Errorf("body template is not valid JSON: %s", b.Bytes())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", j.URL, &b)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for _, h := range strings.FieldsFunc(j.Headers, func(r rune) bool { return r == '\n' || r == ';' }) {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return "", fmt.Errorf("invalid header %q, expecting Name: value", strings.TrimSpace(h))
+		}
+		req.Header.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	reply, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(reply))
+	}
+
+	short, err := jsonField(reply, j.Field)
+	if err != nil {
+		return "", err
+	}
+	if u, err := url.Parse(short); err != nil || !u.IsAbs() {
+		return "", fmt.Errorf("reply field %s is not a link: %q", j.Field, short)
+	}
+	return short, nil
+}
+
+// jsonField returns the string at the dotted path in a JSON object.
+func jsonField(reply []byte, path string) (string, error) {
+	var v any
+	if err := json.Unmarshal(reply, &v); err != nil {
+		return "", fmt.Errorf("expecting json got: %s", reply)
+	}
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("reply has no field %s", path)
+		}
+		if v, ok = obj[key]; !ok {
+			return "", fmt.Errorf("reply has no field %s", path)
+		}
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("reply field %s is not a string", path)
+	}
+	return s, nil
+}
@@ -0,0 +1,62 @@
+package jsontemplate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestShortURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" || r.Header.Get("X-Team") != "a" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"unauthorized"}`))
+			return
+		}
+		var req map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]string{"short_url": "https://s.example/1", "long": req["long_url"], "domain": req["domain"]},
+		})
+	}))
+	defer srv.Close()
+
+	j := New(srv.URL, `{"long_url": {{json .URL}}, "domain": "s.example"}`, "Authorization: Bearer tok; X-Team: a", "data.short_url")
+	// The quote must be escaped by the json function.
+	short, err := j.ShortURL(context.Background(), `https://example.com/?q="x"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if short != "https://s.example/1" {
+		t.Errorf("short = %s", short)
+	}
+
+	j.Field = "data.long"
+	if short, _ := j.ShortURL(context.Background(), "https://example.com/a"); short != "https://example.com/a" {
+		t.Errorf("long URL not sent: %s", short)
+	}
+
+	tests := []struct {
+		j    *JSONTemplate
+		want string
+	}{
+		{New(srv.URL, "", "Authorization: Bearer tok; X-Team: a", "data.missing"), "no field data.missing"},
+		{New(srv.URL, "", "Authorization: Bearer tok; X-Team: a", "data.domain"), "not a link"},
+		{New(srv.URL, `{"url": {{.URL}}}`, "", "data.short_url"), "not valid JSON"},
+		{New(srv.URL, "", "no colon", "data.short_url"), "invalid header"},
+		{New(srv.URL, "", "", "data.short_url"), "HTTP 401"},
+		{New("", "", "", ""), "required"},
+	}
+	for _, tt := range tests {
+		_, err := tt.j.ShortURL(context.Background(), "https://example.com/a")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%+v: err = %v, want %s", tt.j, err, tt.want)
+		}
+	}
+}
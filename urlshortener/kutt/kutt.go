@@ -0,0 +1,82 @@
+package kutt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultURL is the public Kutt instance.
+const DefaultURL = "https://kutt.it"
+
+// Kutt shortens links with kutt.it or a self-hosted Kutt, see
+// https://docs.kutt.it
+type Kutt struct {
+	URL    string
+	APIKey string
+}
+
+// New creates a new Kutt shortener. An empty url uses DefaultURL.
+func New(url, apiKey string) *Kutt {
+	if url == "" {
+		url = DefaultURL
+	}
+	return &Kutt{URL: url, APIKey: apiKey}
+}
+
+// GetName returns the shortener type
+func (k *Kutt) GetName() string {
+	return "kutt"
+}
+
+// SetupQuestions asks for the instance and an API key
+func (k *Kutt) SetupQuestions() map[string]string {
+	return map[string]string{
+		"url":    "Kutt URL (empty for " + DefaultURL + ")",
+		"apikey": "API key (Settings page of Kutt)",
+	}
+}
+
+// ShortURL creates a new link for longURL
+func (k *Kutt) ShortURL(ctx context.Context, longURL string) (string, error) {
+	if k.APIKey == "" {
+		return "", errors.New("apikey is required")
+	}
+	b, err := json.Marshal(map[string]string{"target": longURL})
+	if err != nil {
+		return "", err
+	}
+	endpoint := strings.TrimRight(k.URL, "/") + "/api/v2/links"
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-KEY", k.APIKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+
+	var reply struct {
+		Link  string `json:"link"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &reply); err != nil {
+		return "", fmt.Errorf("HTTP %d, expecting json got: %s", resp.StatusCode, body)
+	}
+	if resp.StatusCode >= 300 || reply.Link == "" {
+		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, reply.Error)
+	}
+	return reply.Link, nil
+}
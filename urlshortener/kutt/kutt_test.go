@@ -0,0 +1,49 @@
+package kutt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestShortURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/v2/links" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("X-API-KEY") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized."})
+			return
+		}
+		var req struct {
+			Target string `json:"target"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"id": "1", "address": "x1", "target": req.Target, "link": "https://kutt.example/x1"})
+	}))
+	defer srv.Close()
+
+	k := New(srv.URL+"/", "key")
+	short, err := k.ShortURL(context.Background(), "https://example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if short != "https://kutt.example/x1" {
+		t.Errorf("short = %s", short)
+	}
+
+	k.APIKey = "wrong"
+	if _, err := k.ShortURL(context.Background(), "https://example.com/a"); err == nil || !strings.Contains(err.Error(), "HTTP 401: Unauthorized.") {
+		t.Errorf("err = %v", err)
+	}
+
+	if New("", "key").URL != DefaultURL {
+		t.Error("empty URL does not default to kutt.it")
+	}
+}
@@ -0,0 +1,84 @@
+package shlink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Shlink shortens links with a Shlink server, see https://shlink.io
+type Shlink struct {
+	URL    string
+	APIKey string
+	// Domain selects one of the server's domains; empty for the default.
+	Domain string
+}
+
+// New creates a new Shlink shortener
+func New(url, apiKey, domain string) *Shlink {
+	return &Shlink{URL: url, APIKey: apiKey, Domain: domain}
+}
+
+// GetName returns the shortener type
+func (s *Shlink) GetName() string {
+	return "shlink"
+}
+
+// SetupQuestions asks for the server, an API key and an optional domain
+func (s *Shlink) SetupQuestions() map[string]string {
+	return map[string]string{
+		"url":    "Shlink server URL (e.g. https://s.example.com)",
+		"apikey": "API key (shlink api-key:generate)",
+		"domain": "Domain for short URLs (empty for the default domain)",
+	}
+}
+
+// ShortURL creates a short URL, or returns the existing one for longURL
+func (s *Shlink) ShortURL(ctx context.Context, longURL string) (string, error) {
+	if s.URL == "" || s.APIKey == "" {
+		return "", errors.New("url and apikey are required")
+	}
+	payload := map[string]any{"longUrl": longURL, "findIfExists": true}
+	if s.Domain != "" {
+		payload["domain"] = s.Domain
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	endpoint := strings.TrimRight(s.URL, "/") + "/rest/v3/short-urls"
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", s.APIKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+
+	// Errors are RFC 7807 problem details.
+	var reply struct {
+		ShortURL string `json:"shortUrl"`
+		Title    string `json:"title"`
+		Detail   string `json:"detail"`
+	}
+	if err := json.Unmarshal(body, &reply); err != nil {
+		return "", fmt.Errorf("HTTP %d, expecting json got: %s", resp.StatusCode, body)
+	}
+	if resp.StatusCode >= 300 || reply.ShortURL == "" {
+		return "", fmt.Errorf("HTTP %d: %s: %s", resp.StatusCode, reply.Title, reply.Detail)
+	}
+	return reply.ShortURL, nil
+}
@@ -0,0 +1,60 @@
+package shlink
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestShortURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/rest/v3/short-urls" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("X-Api-Key") != "key" {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]any{"title": "Invalid API key", "detail": "Provided API key does not exist or is invalid.", "status": 401})
+			return
+		}
+		var req struct {
+			LongURL      string `json:"longUrl"`
+			FindIfExists bool   `json:"findIfExists"`
+			Domain       string `json:"domain"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.LongURL != "https://example.com/a" || !req.FindIfExists {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		domain := req.Domain
+		if domain == "" {
+			domain = "s.example.com"
+		}
+		json.NewEncoder(w).Encode(map[string]any{"shortCode": "abc", "shortUrl": "https://" + domain + "/abc", "longUrl": req.LongURL})
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL, "key", "")
+	short, err := s.ShortURL(context.Background(), "https://example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if short != "https://s.example.com/abc" {
+		t.Errorf("short = %s", short)
+	}
+
+	s.Domain = "go.example.org"
+	if short, _ := s.ShortURL(context.Background(), "https://example.com/a"); short != "https://go.example.org/abc" {
+		t.Errorf("short with domain = %s", short)
+	}
+
+	s.APIKey = "wrong"
+	if _, err := s.ShortURL(context.Background(), "https://example.com/a"); err == nil || !strings.Contains(err.Error(), "HTTP 401: Invalid API key") {
+		t.Errorf("err = %v", err)
+	}
+}
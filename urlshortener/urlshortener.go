@@ -1,8 +1,61 @@
 package urlshortener
 
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"schneider.vip/share/urlshortener/biturl"
+	"schneider.vip/share/urlshortener/jsontemplate"
+	"schneider.vip/share/urlshortener/kutt"
+	"schneider.vip/share/urlshortener/shlink"
+	"schneider.vip/share/urlshortener/yourls"
+)
+
 // URLShortener Interface
 type URLShortener interface {
+	// GetName returns the type stored in the config.
 	GetName() string
+	// SetupQuestions maps the setting keys the shortener needs to the
+	// questions asked during setup.
 	SetupQuestions() map[string]string
-	ShortURL() (string, error)
+	// ShortURL returns a short URL that redirects to longURL.
+	ShortURL(ctx context.Context, longURL string) (string, error)
+}
+
+// Types lists all URL shortener types.
+var Types = []string{"biturl", "yourls", "shlink", "kutt", "json"}
+
+// New creates the URL shortener of the given type. Missing settings are only
+// reported by ShortURL, so New can be used to get the SetupQuestions.
+func New(typ string, settings map[string]string) (URLShortener, error) {
+	switch typ {
+	case "biturl":
+		return biturl.New(), nil
+	case "yourls":
+		return yourls.New(settings["url"], settings["signature"]), nil
+	case "shlink":
+		return shlink.New(settings["url"], settings["apikey"], settings["domain"]), nil
+	case "kutt":
+		return kutt.New(settings["url"], settings["apikey"]), nil
+	case "json":
+		return jsontemplate.New(settings["url"], settings["body"], settings["headers"], settings["field"]), nil
+	default:
+		return nil, fmt.Errorf("unknown URL shortener: %s", typ)
+	}
+}
+
+// Shorten shortens link with s. A URL fragment, like the key of an encrypted
+// upload, is not sent to the shortener but appended to the short URL;
+// browsers keep it across the redirect.
+func Shorten(ctx context.Context, s URLShortener, link string) (string, error) {
+	long, fragment, hasFragment := strings.Cut(link, "#")
+	short, err := s.ShortURL(ctx, long)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", s.GetName(), err)
+	}
+	if hasFragment {
+		short += "#" + fragment
+	}
+	return short, nil
 }
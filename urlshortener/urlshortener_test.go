@@ -0,0 +1,49 @@
+package urlshortener
+
+import (
+	"context"
+	"testing"
+)
+
+// fakeShortener records the URL it was asked to shorten.
+type fakeShortener struct {
+	got string
+}
+
+func (f *fakeShortener) GetName() string                   { return "fake" }
+func (f *fakeShortener) SetupQuestions() map[string]string { return nil }
+func (f *fakeShortener) ShortURL(ctx context.Context, longURL string) (string, error) {
+	f.got = longURL
+	return "https://sho.rt/x", nil
+}
+
+func TestShortenKeepsFragment(t *testing.T) {
+	tests := []struct {
+		link, sent, short string
+	}{
+		{"https://example.com/a.enc?dl=1#key=abc", "https://example.com/a.enc?dl=1", "https://sho.rt/x#key=abc"},
+		{"https://example.com/a", "https://example.com/a", "https://sho.rt/x"},
+	}
+	for _, tt := range tests {
+		f := &fakeShortener{}
+		short, err := Shorten(context.Background(), f, tt.link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.got != tt.sent || short != tt.short {
+			t.Errorf("Shorten(%s): sent %s, got %s", tt.link, f.got, short)
+		}
+	}
+}
+
+func TestNewAllTypes(t *testing.T) {
+	for _, typ := range Types {
+		s, err := New(typ, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.GetName() != typ {
+			t.Errorf("New(%s).GetName() = %s", typ, s.GetName())
+		}
+	}
+}
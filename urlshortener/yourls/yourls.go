@@ -0,0 +1,81 @@
+package yourls
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// YOURLS shortens links with a self-hosted YOURLS instance, see
+// https://yourls.org/docs/guide/advanced/passwordless-api
+type YOURLS struct {
+	URL       string
+	Signature string
+}
+
+// New creates a new YOURLS shortener. url is the YOURLS installation, e.g.
+// https://sho.rt.
+func New(url, signature string) *YOURLS {
+	return &YOURLS{URL: url, Signature: signature}
+}
+
+// GetName returns the shortener type
+func (y *YOURLS) GetName() string {
+	return "yourls"
+}
+
+// SetupQuestions asks for the instance and the API signature token
+func (y *YOURLS) SetupQuestions() map[string]string {
+	return map[string]string{
+		"url":       "YOURLS URL (e.g. https://sho.rt)",
+		"signature": "Signature token (Tools page of the YOURLS admin)",
+	}
+}
+
+// ShortURL calls the shorturl action of yourls-api.php
+func (y *YOURLS) ShortURL(ctx context.Context, longURL string) (string, error) {
+	if y.URL == "" || y.Signature == "" {
+		return "", errors.New("url and signature are required")
+	}
+	form := url.Values{
+		"signature": {y.Signature},
+		"action":    {"shorturl"},
+		"format":    {"json"},
+		"url":       {longURL},
+	}
+	endpoint := strings.TrimRight(y.URL, "/") + "/yourls-api.php"
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+
+	var reply struct {
+		Status   string `json:"status"`
+		Message  string `json:"message"`
+		ShortURL string `json:"shorturl"`
+	}
+	if err := json.Unmarshal(body, &reply); err != nil {
+		return "", fmt.Errorf("HTTP %d, expecting json got: %s", resp.StatusCode, body)
+	}
+	// A URL that was shortened before is reported as failure, but with
+	// its existing short URL.
+	if reply.ShortURL != "" {
+		return reply.ShortURL, nil
+	}
+	return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, reply.Message)
+}
@@ -0,0 +1,52 @@
+package yourls
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestShortURL(t *testing.T) {
+	known := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/yourls-api.php" || r.FormValue("action") != "shorturl" || r.FormValue("format") != "json" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.FormValue("signature") != "sig" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]any{"message": "Please log in", "errorCode": "403"})
+			return
+		}
+		long := r.FormValue("url")
+		if short, ok := known[long]; ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{
+				"status": "fail", "code": "error:url", "message": long + " already exists in database", "shorturl": short,
+			})
+			return
+		}
+		known[long] = "https://sho.rt/1"
+		json.NewEncoder(w).Encode(map[string]any{"status": "success", "shorturl": known[long]})
+	}))
+	defer srv.Close()
+
+	y := New(srv.URL+"/", "sig")
+	for range 2 {
+		short, err := y.ShortURL(context.Background(), "https://example.com/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if short != "https://sho.rt/1" {
+			t.Errorf("short = %s", short)
+		}
+	}
+
+	y.Signature = "wrong"
+	if _, err := y.ShortURL(context.Background(), "https://example.com/b"); err == nil || !strings.Contains(err.Error(), "Please log in") {
+		t.Errorf("err = %v", err)
+	}
+}